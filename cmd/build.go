@@ -1,11 +1,14 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/url"
 	"os"
 	"path"
+	"sort"
+	"strings"
 
 	"github.com/gorilla/feeds"
 	"github.com/michaelenger/brage/files"
@@ -19,6 +22,15 @@ var destinationPath string
 // Whether to clean the assets dir
 var cleanAssetDir bool
 
+// Whether to fail on missing variables and partials
+var strictMode bool
+
+// Whether to minify the generated files and copied assets
+var minifyOutput bool
+
+// Path of the manifest of the fingerprinted assets
+const assetManifestPath = "/assets/manifest.json"
+
 func runBuildCommand(cmd *cobra.Command, args []string) {
 	logger := log.Default()
 
@@ -37,27 +49,110 @@ func runBuildCommand(cmd *cobra.Command, args []string) {
 
 	logger.Printf("Loading site from: %v", sourcePath)
 
-	siteData, err := site.Load(sourcePath)
+	siteData, err := site.Load(sourcePath, site.Options{Strict: strictMode})
 	if err != nil {
 		logger.Fatalf("ERROR! Unable to load site: %v", err)
 	}
 
 	logger.Printf("Building site in: %v", destinationPath)
 
-	assetsDirectory := path.Join(sourcePath, "assets")
-	if fileInfo, err := os.Stat(assetsDirectory); !os.IsNotExist(err) && fileInfo.IsDir() {
-		if cleanAssetDir {
-			err := os.RemoveAll(path.Join(destinationPath, "assets"))
+	copyFile := files.CopyFile
+	copyDirectory := files.CopyDirectory
+	writeFile := func(fileType string, filePath string, contents string) error {
+		return files.WriteFile(filePath, contents)
+	}
+
+	var minifier *files.Minifier
+	if minifyOutput {
+		minifier = files.NewMinifier()
+		copyFile = minifier.CopyFile
+		copyDirectory = minifier.CopyDirectory
+		writeFile = minifier.WriteFile
+	}
+
+	if cleanAssetDir {
+		err := os.RemoveAll(path.Join(destinationPath, "assets"))
+		if err != nil {
+			logger.Fatalf("ERROR! Unable to delete existing assets directory: %v", err)
+		}
+	}
+
+	// Bundles are concatenated from their files and written as a single asset,
+	// along with the generated assets
+	writeAsset := func(asset site.Asset) error {
+		contents, err := asset.Contents()
+		if err != nil {
+			return err
+		}
+		return writeFile(files.MinifyType(asset.Path), path.Join(destinationPath, asset.Path), string(contents))
+	}
+
+	// Copy the theme assets first so that the site can override them. They
+	// are copied as they are when fingerprinting as well, so that anything
+	// which doesn't use the asset lambda (e.g. images in markdown and URLs in
+	// CSS) still finds them
+	for _, directory := range siteData.Directories() {
+		assetsDirectory := path.Join(directory, "assets")
+		if fileInfo, err := os.Stat(assetsDirectory); !os.IsNotExist(err) && fileInfo.IsDir() {
+			assets, err := copyDirectory(assetsDirectory, destinationPath)
 			if err != nil {
-				logger.Fatalf("ERROR! Unable to delete existing assets directory: %v", err)
+				logger.Fatalf("ERROR! Unable to copy assets: %v", err)
 			}
+			logger.Printf("Copied %v assets from: %v", assets, directory)
+		}
+	}
+
+	written := 0
+	for name, asset := range siteData.Assets {
+		if asset.IsFile() {
+			if !siteData.Config.Fingerprint {
+				continue
+			}
+
+			targetPath := path.Join(destinationPath, asset.Path)
+			if err := os.MkdirAll(path.Dir(targetPath), 0755); err != nil {
+				logger.Fatalf("ERROR! Unable to copy assets: %v", err)
+			}
+			if err := copyFile(asset.Source, targetPath); err != nil {
+				logger.Fatalf("ERROR! Unable to copy assets: %v", err)
+			}
+			written++
+			continue
 		}
 
-		assets, err := files.CopyDirectory(assetsDirectory, destinationPath)
+		if err := writeAsset(asset); err != nil {
+			logger.Fatalf("ERROR! Unable to write asset %v: %v", asset.Path, err)
+		}
+		if originalPath := path.Join("/assets", name); originalPath != asset.Path {
+			asset.Path = originalPath
+			if err := writeAsset(asset); err != nil {
+				logger.Fatalf("ERROR! Unable to write asset %v: %v", asset.Path, err)
+			}
+		}
+		written++
+	}
+	if written > 0 {
+		logger.Printf("Wrote %v bundled, generated and fingerprinted assets", written)
+	}
+
+	if siteData.Config.Fingerprint {
+		manifest, err := json.MarshalIndent(siteData.AssetManifest(), "", "  ")
+		if err != nil {
+			logger.Fatalf("ERROR! Unable to create asset manifest: %v", err)
+		}
+		err = files.WriteFile(path.Join(destinationPath, assetManifestPath), string(manifest))
 		if err != nil {
-			logger.Fatalf("ERROR! Unable to copy assets: %v", err)
+			logger.Fatalf("ERROR! Unable to create asset manifest: %v", err)
 		}
-		logger.Printf("Copied %v assets", assets)
+		logger.Printf("Wrote asset manifest: %v", assetManifestPath)
+	}
+
+	pruned, err := siteData.PruneImageCache()
+	if err != nil {
+		logger.Fatalf("ERROR! Unable to prune image cache: %v", err)
+	}
+	if pruned > 0 {
+		logger.Printf("Removed %v stale resized images from the cache", pruned)
 	}
 
 	for uri, targetUrl := range siteData.Config.Redirects {
@@ -88,70 +183,108 @@ func runBuildCommand(cmd *cobra.Command, args []string) {
 		if err != nil {
 			logger.Fatalf("ERROR! Unable to render page file: %v", err)
 		}
-		files.WriteFile(filePath, content)
+		writeFile("html", filePath, content)
 		logger.Printf("Wrote file for: %v", page.Path)
 	}
 
-	logger.Printf("Found %d posts...", len(siteData.Posts))
-	for _, post := range siteData.Posts {
-		filePath := path.Join(destinationPath, post.Path, "index.html")
-		content, err := post.Render(siteData)
-		if err != nil {
-			logger.Fatalf("ERROR! Unable to render post file: %v", err)
-		}
-		files.WriteFile(filePath, content)
-		logger.Printf("Wrote file for: %v", post.Path)
-	}
-
-	if len(siteData.Posts) > 0 {
-		author := &feeds.Author{Name: siteData.Config.Author}
-
-		feed := &feeds.Feed{
-			Title:       siteData.Config.Title,
-			Link:        &feeds.Link{Href: siteData.Config.RootUrl},
-			Description: siteData.Config.Description,
-			Author:      author,
-			Created:     siteData.Posts[len(siteData.Posts)-1].Date,
-			Updated:     siteData.Posts[0].Date,
-		}
-		if siteData.Config.Image != "" {
-			imageUrl, err := url.JoinPath(siteData.Config.RootUrl, "assets", siteData.Config.Image)
+	for name, collection := range siteData.Collections {
+		logger.Printf("Found %d %s...", len(collection.Entries), name)
+		for _, post := range collection.Entries {
+			filePath := path.Join(destinationPath, post.Path, "index.html")
+			content, err := post.Render(siteData)
 			if err != nil {
-				logger.Fatalf("ERROR! Unable to create post URL: %v", err)
+				logger.Fatalf("ERROR! Unable to render post file: %v", err)
+			}
+			writeFile("html", filePath, content)
+			logger.Printf("Wrote file for: %v", post.Path)
+
+			for _, resource := range post.Resources {
+				targetPath := path.Join(destinationPath, resource.Path)
+				if err := os.MkdirAll(path.Dir(targetPath), 0755); err != nil {
+					logger.Fatalf("ERROR! Unable to copy post resources: %v", err)
+				}
+				if err := copyFile(resource.Source, targetPath); err != nil {
+					logger.Fatalf("ERROR! Unable to copy post resources: %v", err)
+				}
 			}
-			feed.Image = &feeds.Image{
-				Url:   imageUrl,
-				Title: siteData.Config.Title,
-				Link:  siteData.Config.RootUrl,
+			if len(post.Resources) > 0 {
+				logger.Printf("Copied %v resources for: %v", len(post.Resources), post.Path)
 			}
 		}
 
-		var feedItems []*feeds.Item
-		for _, post := range siteData.Posts {
-			postUrl, err := url.JoinPath(siteData.Config.RootUrl, post.Path)
+		if collection.Config.Feed != "" && len(collection.Entries) > 0 {
+			content, err := makeFeed(siteData, collection)
 			if err != nil {
-				logger.Fatalf("ERROR! Unable to create post URL: %v", err)
+				logger.Fatalf("ERROR! Unable to generate RSS feed: %v", err)
+			}
+			filePath := path.Join(destinationPath, collection.Config.Feed)
+			writeFile("xml", filePath, content)
+			logger.Printf("Wrote RSS file: %v", collection.Config.Feed)
+		}
+	}
+
+	if minifier != nil {
+		fileTypes := make([]string, 0, len(minifier.Original))
+		for fileType := range minifier.Original {
+			fileTypes = append(fileTypes, fileType)
+		}
+		sort.Strings(fileTypes)
+
+		for _, fileType := range fileTypes {
+			original, minified := minifier.Original[fileType], minifier.Minified[fileType]
+			saved := 0.0
+			if original > 0 {
+				saved = 100 * float64(original-minified) / float64(original)
 			}
-			content, _ := post.RenderTemplate(siteData)
-			feedItems = append(feedItems, &feeds.Item{
-				Title:       post.Title,
-				Link:        &feeds.Link{Href: postUrl},
-				Description: post.Description,
-				Author:      author,
-				Created:     post.Date,
-				Content:     content,
-			})
-		}
-		feed.Items = feedItems
-
-		filePath := path.Join(destinationPath, "feed.rss")
-		content, err := feed.ToRss()
+			logger.Printf("Minified %v: saved %d bytes (%.1f%%)", fileType, original-minified, saved)
+		}
+	}
+}
+
+// Generate an RSS feed for the entries in a collection.
+func makeFeed(siteData site.Site, collection site.Collection) (string, error) {
+	entries := collection.Entries
+	author := &feeds.Author{Name: siteData.Config.Author}
+
+	feed := &feeds.Feed{
+		Title:       siteData.Config.Title,
+		Link:        &feeds.Link{Href: siteData.Config.RootUrl},
+		Description: siteData.Config.Description,
+		Author:      author,
+		Created:     entries[len(entries)-1].Date,
+		Updated:     entries[0].Date,
+	}
+	if siteData.Config.Image != "" {
+		imageUrl, err := url.JoinPath(siteData.Config.RootUrl, siteData.AssetPath(siteData.Config.Image))
 		if err != nil {
-			logger.Fatalf("ERROR! Unable to generate RSS feed: %v", err)
+			return "", err
+		}
+		feed.Image = &feeds.Image{
+			Url:   imageUrl,
+			Title: siteData.Config.Title,
+			Link:  siteData.Config.RootUrl,
 		}
-		files.WriteFile(filePath, content)
-		logger.Print("Wrote RSS file: feed.rss")
 	}
+
+	var feedItems []*feeds.Item
+	for _, post := range entries {
+		postUrl, err := url.JoinPath(siteData.Config.RootUrl, post.Path)
+		if err != nil {
+			return "", err
+		}
+		content, _ := post.RenderTemplate(siteData)
+		feedItems = append(feedItems, &feeds.Item{
+			Title:       post.Title,
+			Link:        &feeds.Link{Href: postUrl},
+			Description: post.Description,
+			Author:      &feeds.Author{Name: strings.Join(siteData.AuthorNames(post), ", ")},
+			Created:     post.Date,
+			Content:     content,
+		})
+	}
+	feed.Items = feedItems
+
+	return feed.ToRss()
 }
 
 var buildCommand = &cobra.Command{
@@ -165,6 +298,8 @@ var buildCommand = &cobra.Command{
 func init() {
 	buildCommand.Flags().StringVarP(&destinationPath, "output", "o", "", "Directory to output files to")
 	buildCommand.Flags().BoolVarP(&cleanAssetDir, "clean", "c", false, "Clean the destination assets directory before building")
+	buildCommand.Flags().BoolVarP(&strictMode, "strict", "s", false, "Fail on missing template variables and partials")
+	buildCommand.Flags().BoolVarP(&minifyOutput, "minify", "m", false, "Minify the generated HTML and feeds, and the CSS, JS and SVG assets")
 
 	rootCmd.AddCommand(buildCommand)
 }
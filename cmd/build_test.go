@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/michaelenger/brage/files"
+)
+
+func TestBuildFingerprintedAssets(t *testing.T) {
+	sourcePath := t.TempDir()
+	test_files := map[string]string{
+		"config.yaml":      "title: Test\nroot_url: https://example.org\nimage: cat.png\nfingerprint_assets: true\n",
+		"pages/index.md":   "![Cat](/assets/cat.png)",
+		"posts/first.md":   "---\ntitle: First\ndate: 2024-01-02\n---\nHello",
+		"assets/cat.png":   "Not really a cat",
+		"assets/style.css": "body { background: url(/assets/cat.png); }",
+	}
+	for name, contents := range test_files {
+		if err := files.WriteFile(path.Join(sourcePath, name), contents); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+
+	defer func() { destinationPath = "" }()
+	destinationPath = t.TempDir()
+	runBuildCommand(buildCommand, []string{sourcePath})
+
+	// The assets are written both with and without their fingerprint
+	for _, name := range []string{"assets/cat.png", "assets/style.css", "assets/manifest.json"} {
+		if _, err := os.Stat(path.Join(destinationPath, name)); err != nil {
+			t.Fatalf("Missing asset %v: %v", name, err)
+		}
+	}
+
+	feed, err := os.ReadFile(path.Join(destinationPath, "feed.rss"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	match := regexp.MustCompile(`<url>https://example.org(/assets/cat\.[0-9a-f]+\.png)</url>`).FindStringSubmatch(string(feed))
+	if match == nil {
+		t.Fatalf("Missing fingerprinted image in feed:\n%v", string(feed))
+	}
+	contents, err := os.ReadFile(path.Join(destinationPath, match[1]))
+	if err != nil {
+		t.Fatalf("Missing feed image: %v", err)
+	}
+	if !strings.Contains(string(contents), "Not really a cat") {
+		t.Fatalf("Incorrect feed image: %v", string(contents))
+	}
+}
@@ -1,7 +1,9 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"html/template"
 	"io"
 	"log"
 	"mime"
@@ -9,6 +11,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/michaelenger/brage/files"
@@ -39,7 +42,7 @@ func runServeCommand(cmd *cobra.Command, args []string) {
 	logger.Printf("Loading site from: %v", sourcePath)
 
 	// Load the site to ensure that everything we need is there
-	_, err := site.Load(sourcePath)
+	_, err := site.Load(sourcePath, site.Options{})
 	if err != nil {
 		logger.Fatalf("ERROR! Unable to load site: %v", err)
 	}
@@ -61,6 +64,70 @@ func runServeCommand(cmd *cobra.Command, args []string) {
 	logger.Fatal(server.ListenAndServe())
 }
 
+// Template for the page shown when a page or post fails to render.
+var errorPageTemplate = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+	<head>
+		<title>{{ .Title }}</title>
+		<style>
+			body { font-family: sans-serif; margin: 2em; color: #222; }
+			h1 { color: #b00; }
+			pre { background: #f6f6f6; padding: 1em; overflow: auto; }
+			.current { background: #fdd; display: inline-block; width: 100%; }
+		</style>
+	</head>
+	<body>
+		<h1>{{ .Title }}</h1>
+		{{ if .File }}<p><strong>{{ .File }}</strong>{{ if .Line }} line {{ .Line }}{{ if .Column }}, column {{ .Column }}{{ end }}{{ end }}</p>{{ end }}
+		<pre>{{ .Message }}</pre>
+		{{ if .Lines }}<pre>{{ range .Lines }}<span{{ if .Current }} class="current"{{ end }}>{{ printf "%4d" .Number }}  {{ .Text }}</span>
+{{ end }}</pre>{{ end }}
+	</body>
+</html>`))
+
+// A line of source shown on the error page.
+type errorPageLine struct {
+	Number  int
+	Text    string
+	Current bool
+}
+
+// Respond with a page describing an error which occurred when rendering,
+// including the surrounding source if the location of the error is known.
+func (handler *siteHandler) serveError(title string, err error, w http.ResponseWriter) {
+	handler.logger.Print("500 Server Error")
+	handler.logger.Printf("%v: %v", title, err)
+
+	data := map[string]interface{}{
+		"Title":   title,
+		"Message": err.Error(),
+	}
+
+	var templateError site.TemplateError
+	if errors.As(err, &templateError) {
+		data["File"] = templateError.File
+		data["Line"] = templateError.Line
+		data["Column"] = templateError.Column
+		data["Message"] = templateError.Err.Error()
+
+		if contents, readErr := os.ReadFile(templateError.File); readErr == nil && templateError.Line > 0 {
+			sourceLines := strings.Split(string(contents), "\n")
+			lines := []errorPageLine{}
+			for i := templateError.Line - 3; i < templateError.Line+2 && i < len(sourceLines); i++ {
+				if i < 0 {
+					continue
+				}
+				lines = append(lines, errorPageLine{i + 1, sourceLines[i], i+1 == templateError.Line})
+			}
+			data["Lines"] = lines
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(http.StatusInternalServerError)
+	errorPageTemplate.Execute(w, data)
+}
+
 func (handler *siteHandler) serveFile(assetFile string, w http.ResponseWriter, r *http.Request) {
 	if _, err := os.Stat(assetFile); err != nil {
 		handler.logger.Print("404 Not Found")
@@ -84,22 +151,57 @@ func (handler *siteHandler) serveFile(assetFile string, w http.ResponseWriter, r
 	w.Write(fileBytes)
 }
 
+// Respond with the contents of an asset bundle or a generated asset.
+func (handler *siteHandler) serveAsset(asset site.Asset, w http.ResponseWriter) {
+	contents, err := asset.Contents()
+	if err != nil {
+		handler.logger.Print("500 Server Error")
+		errorText := fmt.Sprintf("Unable to read asset: %v", err)
+		handler.logger.Print(errorText)
+		http.Error(w, errorText, 500)
+		return
+	}
+
+	mimeType := mime.TypeByExtension(path.Ext(asset.Path))
+	handler.logger.Printf("200 OK %v", mimeType)
+	w.Header().Set("Content-Type", mimeType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(contents)
+}
+
 // Handle an HTTP request on the server
 func (handler *siteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	requestPath := r.URL.Path
 	handler.logger.Printf("Request: %v %v", r.Method, requestPath)
 
-	site, err := site.Load(handler.sitePath)
+	site, err := site.Load(handler.sitePath, site.Options{})
 	if err != nil {
 		handler.logger.Fatalf("ERROR! Unable to load site: %v", err)
 	}
 
+	if asset, exists := site.FindAsset(requestPath); exists {
+		if !asset.IsFile() {
+			handler.serveAsset(asset, w)
+		} else {
+			handler.serveFile(asset.Source, w, r)
+		}
+		return
+	}
+
 	if len(requestPath) >= 7 && requestPath[:7] == "/assets" {
-		assetPath := path.Join(site.SourceDirectory, requestPath)
+		assetPath, exists := site.ResolvePath(requestPath)
+		if !exists {
+			assetPath = path.Join(site.SourceDirectory, requestPath)
+		}
 		handler.serveFile(assetPath, w, r)
 		return
 	}
 
+	if resource, exists := site.FindResource(requestPath); exists {
+		handler.serveFile(resource.Source, w, r)
+		return
+	}
+
 	target, exists := site.Config.Redirects[requestPath]
 	if exists {
 		handler.logger.Print("302 Found")
@@ -111,10 +213,7 @@ func (handler *siteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		if page.Path == requestPath {
 			content, err := page.Render(site)
 			if err != nil {
-				handler.logger.Print("500 Server Error")
-				errorText := fmt.Sprintf("Unable to render page file: %v", err)
-				handler.logger.Print(errorText)
-				http.Error(w, errorText, http.StatusInternalServerError)
+				handler.serveError("Unable to render page file", err, w)
 				return
 			}
 
@@ -126,14 +225,15 @@ func (handler *siteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	for _, post := range site.Posts {
-		if post.Path == requestPath {
+	for _, collection := range site.Collections {
+		for _, post := range collection.Entries {
+			if post.Path != requestPath {
+				continue
+			}
+
 			content, err := post.Render(site)
 			if err != nil {
-				handler.logger.Print("500 Server Error")
-				errorText := fmt.Sprintf("Unable to render post file: %v", err)
-				handler.logger.Print(errorText)
-				http.Error(w, errorText, http.StatusInternalServerError)
+				handler.serveError("Unable to render post file", err, w)
 				return
 			}
 
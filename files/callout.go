@@ -0,0 +1,236 @@
+package files
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// A callout in markdown, e.g. a note or a warning.
+type Callout struct {
+	Kind    string
+	Title   string
+	Content string
+}
+
+// A function which renders a callout to HTML.
+type CalloutFunc func(callout Callout) (string, error)
+
+// The kind of a callout node.
+var KindCallout = ast.NewNodeKind("Callout")
+
+// A callout block, containing the blocks of its content.
+type CalloutBlock struct {
+	ast.BaseBlock
+	CalloutKind string
+	Title       string
+	fence       int
+}
+
+func (node *CalloutBlock) Kind() ast.NodeKind {
+	return KindCallout
+}
+
+func (node *CalloutBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(node, source, level, map[string]string{"Kind": node.CalloutKind, "Title": node.Title}, nil)
+}
+
+// Pattern matching the first line of a GitHub-style alert, e.g. "[!NOTE]".
+var alertPattern = regexp.MustCompile(`^\[!(\w+)\][ \t]*(.*?)\s*$`)
+
+// Pattern matching the opening line of a fenced callout, e.g. ":::tip Title".
+var calloutFencePattern = regexp.MustCompile(`^(:{3,})[ \t]*(\w+)[ \t]*(.*?)\s*$`)
+
+// Pattern matching the closing line of a fenced callout.
+var calloutClosingPattern = regexp.MustCompile(`^(:{3,})\s*$`)
+
+// Parser for callouts fenced by ":::" lines.
+type calloutBlockParser struct{}
+
+func (p *calloutBlockParser) Trigger() []byte {
+	return []byte{':'}
+}
+
+func (p *calloutBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	match := calloutFencePattern.FindSubmatch(util.TrimLeftSpace(line))
+	if match == nil {
+		return nil, parser.NoChildren
+	}
+
+	advanceLine(reader, line, segment)
+
+	return &CalloutBlock{
+		CalloutKind: strings.ToLower(string(match[2])),
+		Title:       string(match[3]),
+		fence:       len(match[1]),
+	}, parser.HasChildren
+}
+
+func (p *calloutBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	line, segment := reader.PeekLine()
+	if line == nil {
+		return parser.Close
+	}
+
+	// Callouts can be nested by using more colons for the outer one
+	match := calloutClosingPattern.FindSubmatch(util.TrimLeftSpace(line))
+	if match != nil && len(match[1]) >= node.(*CalloutBlock).fence {
+		advanceLine(reader, line, segment)
+		return parser.Close
+	}
+
+	return parser.Continue | parser.HasChildren
+}
+
+func (p *calloutBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {
+}
+
+func (p *calloutBlockParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (p *calloutBlockParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+// Transformer turning blockquotes starting with "[!KIND]" into callouts.
+type alertTransformer struct{}
+
+func (t *alertTransformer) Transform(document *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+
+	blockquotes := []*ast.Blockquote{}
+	ast.Walk(document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if blockquote, ok := node.(*ast.Blockquote); ok && entering {
+			blockquotes = append(blockquotes, blockquote)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, blockquote := range blockquotes {
+		paragraph, ok := blockquote.FirstChild().(*ast.Paragraph)
+		if !ok || paragraph.Lines().Len() == 0 {
+			continue
+		}
+
+		firstLine := paragraph.Lines().At(0)
+		match := alertPattern.FindSubmatch(firstLine.Value(source))
+		if match == nil {
+			continue
+		}
+
+		// Remove the first line from the paragraph, along with the paragraph
+		// itself if there is nothing else in it
+		for child := paragraph.FirstChild(); child != nil; {
+			next := child.NextSibling()
+			if start := nodeStart(child); start < 0 || start >= firstLine.Stop {
+				break
+			}
+			paragraph.RemoveChild(paragraph, child)
+			child = next
+		}
+		if paragraph.ChildCount() == 0 {
+			blockquote.RemoveChild(blockquote, paragraph)
+		}
+
+		callout := &CalloutBlock{
+			CalloutKind: strings.ToLower(string(match[1])),
+			Title:       string(match[2]),
+		}
+		for child := blockquote.FirstChild(); child != nil; {
+			next := child.NextSibling()
+			callout.AppendChild(callout, child)
+			child = next
+		}
+		blockquote.Parent().ReplaceChild(blockquote.Parent(), blockquote, callout)
+	}
+}
+
+// Get the position of the first text in an inline node.
+func nodeStart(node ast.Node) int {
+	start := -1
+	ast.Walk(node, func(child ast.Node, entering bool) (ast.WalkStatus, error) {
+		if text, ok := child.(*ast.Text); ok && entering {
+			start = text.Segment.Start
+			return ast.WalkStop, nil
+		}
+		return ast.WalkContinue, nil
+	})
+
+	return start
+}
+
+// Renderer for callouts, using a custom function if there is one.
+type calloutRenderer struct {
+	markdown goldmark.Markdown
+	render   CalloutFunc
+}
+
+func (r *calloutRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindCallout, r.renderCallout)
+}
+
+func (r *calloutRenderer) renderCallout(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkSkipChildren, nil
+	}
+
+	var content bytes.Buffer
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		if err := r.markdown.Renderer().Render(&content, source, child); err != nil {
+			return ast.WalkStop, err
+		}
+	}
+
+	block := node.(*CalloutBlock)
+	callout := Callout{Kind: block.CalloutKind, Title: block.Title, Content: content.String()}
+
+	if r.render != nil {
+		output, err := r.render(callout)
+		if err == nil {
+			w.WriteString(output)
+			return ast.WalkSkipChildren, nil
+		}
+
+		logger := log.Default()
+		logger.Printf("Unable to render callout: %v", err)
+	}
+
+	w.WriteString(DefaultCallout(callout))
+	return ast.WalkSkipChildren, nil
+}
+
+// Render a callout as an aside with an optional title.
+func DefaultCallout(callout Callout) string {
+	title := ""
+	if callout.Title != "" {
+		title = fmt.Sprintf("<p class=\"callout-title\">%v</p>\n", html.EscapeString(callout.Title))
+	}
+
+	return fmt.Sprintf("<aside class=\"callout callout-%v\">\n%v%v</aside>\n", html.EscapeString(callout.Kind), title, callout.Content)
+}
+
+// Extension parsing callouts, either as GitHub-style alerts or as fenced
+// containers.
+type calloutExtension struct {
+	render CalloutFunc
+}
+
+func (e *calloutExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(util.Prioritized(&calloutBlockParser{}, 710)),
+		parser.WithASTTransformers(util.Prioritized(&alertTransformer{}, 100)),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(&calloutRenderer{m, e.render}, 500)))
+}
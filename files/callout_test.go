@@ -0,0 +1,67 @@
+package files
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRenderCallouts(t *testing.T) {
+	result := RenderMarkdown([]byte(`> [!NOTE]
+> Some *text*
+
+> [!WARNING] Be careful
+> Hot
+
+> Just a quote
+
+::::tip Nested
+:::caution
+Inner
+:::
+- Outer
+::::
+`))
+	expected := `<aside class="callout callout-note">
+<p>Some <em>text</em></p>
+</aside>
+<aside class="callout callout-warning">
+<p class="callout-title">Be careful</p>
+<p>Hot</p>
+</aside>
+<blockquote>
+<p>Just a quote</p>
+</blockquote>
+<aside class="callout callout-tip">
+<p class="callout-title">Nested</p>
+<aside class="callout callout-caution">
+<p>Inner</p>
+</aside>
+<ul>
+<li>Outer</li>
+</ul>
+</aside>
+`
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+}
+
+func TestCalloutFunc(t *testing.T) {
+	markdown := newTestMarkdown(t, MarkdownConfig{Callout: func(callout Callout) (string, error) {
+		if callout.Kind == "broken" {
+			return "", fmt.Errorf("Broken")
+		}
+		return fmt.Sprintf("<div class=%q title=%q>%v</div>\n", callout.Kind, callout.Title, callout.Content), nil
+	}})
+
+	result := markdown.Render([]byte(":::info Title\n**Hi**\n:::\n\n> [!BROKEN]\n> Falls back\n"))
+	expected := `<div class="info" title="Title"><p><strong>Hi</strong></p>
+</div>
+<aside class="callout callout-broken">
+<p>Falls back</p>
+</aside>
+`
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+}
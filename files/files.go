@@ -1,6 +1,8 @@
 package files
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"os"
@@ -35,6 +37,21 @@ func (f File) Render() string {
 	}
 }
 
+// Parse the file, returning the metadata from its front matter, the rendered
+// content, and the headings in markdown files (which are rendered using the
+// given renderer).
+func (f File) Parse(markdown *Markdown) (map[string]interface{}, string, []Heading) {
+	metadata, content := ParseFrontMatter(f.Content)
+
+	switch f.Type {
+	case MarkdownFile:
+		html, headings := markdown.RenderWithHeadings(content)
+		return metadata, html, headings
+	default:
+		return metadata, string(content), nil
+	}
+}
+
 // Convert a relative path to an absolute path, relative to the current
 // working directory.
 func AbsolutePath(relativePath string) string {
@@ -48,6 +65,11 @@ func AbsolutePath(relativePath string) string {
 
 // Copy a directory into another.
 func CopyDirectory(sourceDirectory string, targetDirectory string) (int, error) {
+	return copyDirectory(sourceDirectory, targetDirectory, CopyFile)
+}
+
+// Copy a directory using the given function to copy each file.
+func copyDirectory(sourceDirectory string, targetDirectory string, copyFile func(string, string) error) (int, error) {
 	count := 0
 
 	files, err := os.ReadDir(sourceDirectory)
@@ -66,7 +88,7 @@ func CopyDirectory(sourceDirectory string, targetDirectory string) (int, error)
 		sourcePath := path.Join(sourceDirectory, file.Name())
 
 		if file.IsDir() {
-			subcount, err := CopyDirectory(sourcePath, targetDirectory)
+			subcount, err := copyDirectory(sourcePath, targetDirectory, copyFile)
 			if err != nil {
 				return count, err
 			}
@@ -74,7 +96,7 @@ func CopyDirectory(sourceDirectory string, targetDirectory string) (int, error)
 			count += subcount
 		} else {
 			targetPath := path.Join(targetDirectory, file.Name())
-			if err := CopyFile(sourcePath, targetPath); err != nil {
+			if err := copyFile(sourcePath, targetPath); err != nil {
 				return count, err
 			}
 
@@ -121,6 +143,15 @@ func FileName(filePath string) string {
 	return base[:len(base)-len(extension)]
 }
 
+// Add a hash of the contents of a file to its path, before the extension,
+// e.g. "style.css" becomes "style.3f9a1c2b.css".
+func FingerprintPath(filePath string, contents []byte) string {
+	hash := sha256.Sum256(contents)
+	extension := path.Ext(filePath)
+
+	return fmt.Sprintf("%v.%v%v", filePath[:len(filePath)-len(extension)], hex.EncodeToString(hash[:4]), extension)
+}
+
 // Convert a path into a page/post ID.
 func PathToIdentifier(filePath string) string {
 	if filePath == "/" {
@@ -145,6 +176,84 @@ func PathToTitle(filePath string) string {
 			"-", " "))
 }
 
+// Get the type of a file based on its extension.
+func fileTypeOf(filename string) FileType {
+	switch strings.TrimPrefix(filepath.Ext(filename), ".") {
+	case "html", "htm":
+		return HtmlFile
+	case "markdown", "md":
+		return MarkdownFile
+	default:
+		return UnknownFile
+	}
+}
+
+// Check whether a file is markdown based on its extension.
+func IsMarkdownFile(filePath string) bool {
+	return fileTypeOf(filePath) == MarkdownFile
+}
+
+// Check whether a directory has a markdown index file, i.e. whether it's a
+// bundle of content and the files next to it.
+func IsBundle(directoryPath string) bool {
+	entries, err := os.ReadDir(directoryPath)
+	if err != nil {
+		return false
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() && fileTypeOf(name) == MarkdownFile && strings.TrimSuffix(name, filepath.Ext(name)) == "index" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Recursively find the files in a bundle directory which aren't HTML or
+// markdown and returns a map of their path relative to the directory to their
+// full path. Subdirectories which are bundles of their own are skipped.
+func ReadResources(directoryPath string) (map[string]string, error) {
+	resources := map[string]string{}
+
+	entries, err := os.ReadDir(directoryPath)
+	if err != nil {
+		return resources, err
+	}
+
+	for _, entry := range entries {
+		filename := entry.Name()
+		if filename[0] == '.' {
+			continue
+		}
+
+		fullPath := path.Join(directoryPath, filename)
+
+		if entry.IsDir() {
+			if IsBundle(fullPath) {
+				continue
+			}
+
+			subresources, err := ReadResources(fullPath)
+			if err != nil {
+				return resources, err
+			}
+			for name, resourcePath := range subresources {
+				resources[path.Join(filename, name)] = resourcePath
+			}
+
+			continue
+		}
+
+		if fileTypeOf(filename) == UnknownFile {
+			resources[filename] = fullPath
+		}
+	}
+
+	return resources, nil
+}
+
 // Recursively read files and returns a map of their path to their content, relative to the directory path.
 func ReadFiles(directoryPath string, pathPrefix string) (map[string]File, error) {
 	pages := map[string]File{}
@@ -175,14 +284,8 @@ func ReadFiles(directoryPath string, pathPrefix string) (map[string]File, error)
 		}
 
 		fileExtension := filepath.Ext(filename)
-		filetype := UnknownFile
-
-		switch fileExtension[1:] {
-		case "html", "htm":
-			filetype = HtmlFile
-		case "markdown", "md":
-			filetype = MarkdownFile
-		default:
+		filetype := fileTypeOf(filename)
+		if filetype == UnknownFile {
 			continue
 		}
 
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -43,6 +44,18 @@ func TestPathToTitle(t *testing.T) {
 	}
 }
 
+func TestFingerprintPath(t *testing.T) {
+	result := FingerprintPath("/assets/css/style.css", []byte("body {}"))
+	expected := "/assets/css/style.62368a1a.css"
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+
+	if FingerprintPath("/assets/style.css", []byte("p {}")) == result {
+		t.Fatalf("Expected different contents to give a different path")
+	}
+}
+
 func TestReadFiles(t *testing.T) {
 	test_files := map[string]string{
 		".hidden.html":       "",
@@ -101,3 +114,135 @@ func TestReadFiles(t *testing.T) {
 		t.Fatalf("Received:\n%+v\nExpected:\n%+v", result, expected)
 	}
 }
+
+func TestParseFrontMatter(t *testing.T) {
+	metadata, content := ParseFrontMatter([]byte("---\ntitle: Test\nsource: data/people.yaml\n---\n<p>Content</p>"))
+	expectedMetadata := map[string]interface{}{
+		"title":  "Test",
+		"source": "data/people.yaml",
+	}
+	if !reflect.DeepEqual(metadata, expectedMetadata) {
+		t.Fatalf("Received:\n%+v\nExpected:\n%+v", metadata, expectedMetadata)
+	}
+	if string(content) != "<p>Content</p>" {
+		t.Fatalf("Incorrect content: %v", string(content))
+	}
+
+	metadata, content = ParseFrontMatter([]byte("<p>No front matter</p>"))
+	if metadata != nil {
+		t.Fatalf("Expected nil metadata but got: %+v", metadata)
+	}
+	if string(content) != "<p>No front matter</p>" {
+		t.Fatalf("Incorrect content: %v", string(content))
+	}
+
+	metadata, content = ParseFrontMatter([]byte("---\nnot closed"))
+	if metadata != nil {
+		t.Fatalf("Expected nil metadata but got: %+v", metadata)
+	}
+	if string(content) != "---\nnot closed" {
+		t.Fatalf("Incorrect content: %v", string(content))
+	}
+}
+
+func TestParseFrontMatterWithLine(t *testing.T) {
+	tests := map[string]int{
+		"---\ntitle: Test\n---\n<p>Content</p>":           4,
+		"---\r\ntitle: Test\r\n---\r\n<p>Content</p>":     4,
+		"---\r\ntitle: Test\r\nmore: yes\r\n---\r\n\r\nX": 5,
+		"---\n---\nEmpty front matter":                    3,
+		"<p>No front matter</p>":                          1,
+	}
+	for content, expected := range tests {
+		_, body, line := ParseFrontMatterWithLine([]byte(content))
+		if line != expected {
+			t.Fatalf("Result for %q:\n%v\nExpected:\n%v", content, line, expected)
+		}
+		if lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n"); lines[line-1] != strings.SplitN(string(body), "\n", 2)[0] {
+			t.Fatalf("Line %v of %q doesn't match the body: %q", line, content, body)
+		}
+	}
+}
+
+func TestFileParse(t *testing.T) {
+	file := File{
+		Type:    MarkdownFile,
+		Path:    "/tmp/test.md",
+		Content: []byte("---\ntitle: Test\n---\nSome _markdown_"),
+	}
+
+	metadata, content, headings := file.Parse(defaultMarkdown)
+	if metadata["title"] != "Test" {
+		t.Fatalf("Incorrect metadata: %+v", metadata)
+	}
+	if content != "<p>Some <em>markdown</em></p>\n" {
+		t.Fatalf("Incorrect content: %v", content)
+	}
+	if headings != nil {
+		t.Fatalf("Incorrect headings: %+v", headings)
+	}
+}
+
+func TestReadResources(t *testing.T) {
+	temporaryDirectory, err := os.MkdirTemp("", "bundle")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.RemoveAll(temporaryDirectory)
+
+	test_files := map[string]string{
+		"index.md":             "A post",
+		"notes.md":             "Not a resource",
+		"photo.jpg":            "photo",
+		"LICENSE":              "no extension",
+		".hidden.png":          "",
+		"images/map.png":       "map",
+		"day-two/index.md":     "Another post",
+		"day-two/sunset.jpg":   "sunset",
+		"extras/index.html":    "Not a bundle",
+		"extras/ticket.pdf":    "ticket",
+		"downloads/route.gpx":  "route",
+		"downloads/.gitignore": "",
+	}
+	for filename, contents := range test_files {
+		filePath := path.Join(temporaryDirectory, filename)
+		if err := os.MkdirAll(path.Dir(filePath), 0755); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if err := os.WriteFile(filePath, []byte(contents), 0644); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+
+	if !IsBundle(temporaryDirectory) || !IsBundle(path.Join(temporaryDirectory, "day-two")) {
+		t.Fatalf("Expected the directories with an index file to be bundles")
+	}
+	if IsBundle(path.Join(temporaryDirectory, "images")) || IsBundle(path.Join(temporaryDirectory, "extras")) {
+		t.Fatalf("Expected the directories without a markdown index file to not be bundles")
+	}
+
+	// Subdirectories which are bundles have their own resources
+	result, err := ReadResources(temporaryDirectory)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := map[string]string{
+		"photo.jpg":           path.Join(temporaryDirectory, "photo.jpg"),
+		"LICENSE":             path.Join(temporaryDirectory, "LICENSE"),
+		"images/map.png":      path.Join(temporaryDirectory, "images/map.png"),
+		"downloads/route.gpx": path.Join(temporaryDirectory, "downloads/route.gpx"),
+		"extras/ticket.pdf":   path.Join(temporaryDirectory, "extras/ticket.pdf"),
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("Received:\n%+v\nExpected:\n%+v", result, expected)
+	}
+
+	// Files without an extension are skipped when reading content
+	files, err := ReadFiles(temporaryDirectory, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(files) != 4 {
+		t.Fatalf("Incorrect files: %+v", files)
+	}
+}
@@ -0,0 +1,52 @@
+package files
+
+import (
+	"bytes"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Split YAML front matter from the rest of the content, returning the
+// metadata as a map. Content without (valid) front matter is returned as-is.
+func ParseFrontMatter(content []byte) (map[string]interface{}, []byte) {
+	metadata, body, _ := ParseFrontMatterWithLine(content)
+
+	return metadata, body
+}
+
+// Split YAML front matter from the rest of the content like ParseFrontMatter,
+// also returning the line in the content where the rest of it starts.
+func ParseFrontMatterWithLine(content []byte) (map[string]interface{}, []byte, int) {
+	normalised := bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	if !bytes.HasPrefix(normalised, []byte("---\n")) {
+		return nil, content, 1
+	}
+
+	rest := normalised[4:]
+	var frontMatter []byte
+	var body []byte
+	if bytes.HasPrefix(rest, []byte("---\n")) || bytes.Equal(rest, []byte("---")) {
+		body = bytes.TrimPrefix(rest[3:], []byte("\n"))
+	} else {
+		end := bytes.Index(rest, []byte("\n---"))
+		if end < 0 {
+			return nil, content, 1
+		}
+		frontMatter = rest[:end+1]
+		body = rest[end+4:]
+		if len(body) > 0 && body[0] != '\n' {
+			return nil, content, 1
+		}
+		body = bytes.TrimPrefix(body, []byte("\n"))
+	}
+
+	metadata := map[string]interface{}{}
+	if err := yaml.Unmarshal(frontMatter, &metadata); err != nil {
+		return nil, content, 1
+	}
+
+	// The body is the end of the normalised content, which has the same lines
+	line := bytes.Count(normalised[:len(normalised)-len(body)], []byte("\n")) + 1
+
+	return metadata, body, line
+}
@@ -0,0 +1,273 @@
+package files
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+	"golang.org/x/image/draw"
+)
+
+// Config for the resized variants of images, which are made when at least one
+// width is set.
+type ImageConfig struct {
+	Widths         []int
+	Quality        int
+	PngCompression string `yaml:"png_compression"`
+	Format         string
+	Sizes          string
+}
+
+// The image formats which can be resized, based on their extension.
+var imageFormats = map[string]string{
+	".jpg":  "jpeg",
+	".jpeg": "jpeg",
+	".png":  "png",
+}
+
+// The extensions used for each image format.
+var imageExtensions = map[string]string{
+	"jpeg": ".jpg",
+	"png":  ".png",
+}
+
+// The compression levels which can be used for PNG images.
+var pngCompressionLevels = map[string]png.CompressionLevel{
+	"":        png.DefaultCompression,
+	"default": png.DefaultCompression,
+	"none":    png.NoCompression,
+	"speed":   png.BestSpeed,
+	"best":    png.BestCompression,
+}
+
+// A function returning the attributes added to an image, e.g. its srcset
+// and dimensions. A "src" attribute replaces the destination of the image.
+type ImageAttributesFunc func(destination string) map[string]string
+
+// Get the format of an image which can be resized, or nothing if it can't.
+func ImageFormat(filePath string) string {
+	return imageFormats[strings.ToLower(path.Ext(filePath))]
+}
+
+// Check that the format and PNG compression of the config are known.
+func (config ImageConfig) Validate() error {
+	if _, ok := imageExtensions[config.Format]; config.Format != "" && !ok {
+		return fmt.Errorf("Unknown image format: %v", config.Format)
+	}
+	if _, ok := pngCompressionLevels[config.PngCompression]; !ok {
+		return fmt.Errorf("Unknown PNG compression: %v", config.PngCompression)
+	}
+
+	return nil
+}
+
+// Get the format the variants of an image are written in.
+func (config ImageConfig) variantFormat(filePath string) string {
+	if config.Format != "" {
+		return config.Format
+	}
+
+	return ImageFormat(filePath)
+}
+
+// Get the path of a resized variant of an image, e.g. "cat.640w.jpg".
+func (config ImageConfig) VariantPath(filePath string, width int) string {
+	extension := path.Ext(filePath)
+	if format := config.Format; format != "" && format != ImageFormat(filePath) {
+		extension = imageExtensions[format]
+	}
+
+	return fmt.Sprintf("%v.%dw%v", strings.TrimSuffix(filePath, path.Ext(filePath)), width, extension)
+}
+
+// Get the width and height of an image file.
+func ImageSize(filePath string) (int, int, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return config.Width, config.Height, nil
+}
+
+// Resize an image to the given width, keeping its aspect ratio, and encode it
+// in the given format ("jpeg" or "png").
+func ResizeImage(contents []byte, width int, format string, config ImageConfig) ([]byte, error) {
+	source, _, err := image.Decode(bytes.NewReader(contents))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := source.Bounds()
+	height := (bounds.Dy()*width + bounds.Dx()/2) / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+
+	resized := image.NewNRGBA(image.Rect(0, 0, width, height))
+	if format == "jpeg" {
+		// JPEG has no transparency, so it's placed on a white background
+		draw.Draw(resized, resized.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	}
+	draw.CatmullRom.Scale(resized, resized.Bounds(), source, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	switch format {
+	case "jpeg":
+		quality := config.Quality
+		if quality <= 0 {
+			quality = jpeg.DefaultQuality
+		}
+		err = jpeg.Encode(&buf, resized, &jpeg.Options{Quality: quality})
+	case "png":
+		level, ok := pngCompressionLevels[config.PngCompression]
+		if !ok {
+			return nil, fmt.Errorf("Unknown PNG compression: %v", config.PngCompression)
+		}
+		encoder := png.Encoder{CompressionLevel: level}
+		err = encoder.Encode(&buf, resized)
+	default:
+		return nil, fmt.Errorf("Unknown image format: %v", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Get the name of the file an image resized to the given width is cached in.
+func imageCacheName(contents []byte, width int, format string, config ImageConfig) string {
+	// Anything affecting the result is part of the key
+	hash := sha256.New()
+	hash.Write(contents)
+	fmt.Fprintf(hash, "\n%d\n%v\n%d\n%v", width, format, config.Quality, config.PngCompression)
+
+	return hex.EncodeToString(hash.Sum(nil)) + imageExtensions[format]
+}
+
+// Get the name of the file in the cache directory which an image file
+// resized to the given width is cached in.
+func ImageCacheName(sourceFilePath string, width int, config ImageConfig) (string, error) {
+	contents, err := os.ReadFile(sourceFilePath)
+	if err != nil {
+		return "", err
+	}
+
+	return imageCacheName(contents, width, config.variantFormat(sourceFilePath), config), nil
+}
+
+// Remove the files in the cache directory which aren't in the given names,
+// returning how many were removed.
+func PruneImageCache(cacheDirectory string, names map[string]bool) (int, error) {
+	entries, err := os.ReadDir(cacheDirectory)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || names[entry.Name()] {
+			continue
+		}
+		if err := os.Remove(path.Join(cacheDirectory, entry.Name())); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+
+	return removed, nil
+}
+
+// Resize an image file to the given width, reusing the result of resizing it
+// previously if it's in the cache directory.
+func ResizeImageCached(cacheDirectory string, sourceFilePath string, width int, config ImageConfig) ([]byte, error) {
+	contents, err := os.ReadFile(sourceFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	format := config.variantFormat(sourceFilePath)
+	cachePath := path.Join(cacheDirectory, imageCacheName(contents, width, format, config))
+
+	if cached, err := os.ReadFile(cachePath); err == nil {
+		return cached, nil
+	}
+
+	resized, err := ResizeImage(contents, width, format, config)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(cacheDirectory, 0755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(cachePath, resized, 0644); err != nil {
+		return nil, err
+	}
+
+	return resized, nil
+}
+
+// Transformer adding attributes to images, e.g. their srcset and dimensions.
+type imageTransformer struct {
+	attributes ImageAttributesFunc
+}
+
+func (t *imageTransformer) Transform(document *ast.Document, reader text.Reader, pc parser.Context) {
+	ast.Walk(document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		imageNode, ok := node.(*ast.Image)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+
+		attributes := t.attributes(string(imageNode.Destination))
+		names := make([]string, 0, len(attributes))
+		for name := range attributes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if name == "src" {
+				imageNode.Destination = []byte(attributes[name])
+				continue
+			}
+			imageNode.SetAttributeString(name, []byte(attributes[name]))
+		}
+
+		return ast.WalkSkipChildren, nil
+	})
+}
+
+// Extension adding attributes to images.
+type imageExtension struct {
+	attributes ImageAttributesFunc
+}
+
+func (e *imageExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(util.Prioritized(&imageTransformer{e.attributes}, 500)))
+}
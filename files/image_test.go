@@ -0,0 +1,204 @@
+package files
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path"
+	"testing"
+)
+
+// Make a PNG image with the given dimensions.
+func makeTestImage(t *testing.T, width int, height int) []byte {
+	source := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			source.Set(x, y, color.NRGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, source); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestImageVariantPath(t *testing.T) {
+	tests := map[string]string{
+		"/assets/cat.jpg":           "/assets/cat.640w.jpg",
+		"/assets/photos/dog.PNG":    "/assets/photos/dog.640w.PNG",
+		"/assets/cat.62368a1a.jpeg": "/assets/cat.62368a1a.640w.jpeg",
+	}
+	for filePath, expected := range tests {
+		if result := (ImageConfig{}).VariantPath(filePath, 640); result != expected {
+			t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+		}
+	}
+
+	config := ImageConfig{Format: "jpeg"}
+	if result := config.VariantPath("/assets/dog.png", 320); result != "/assets/dog.320w.jpg" {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, "/assets/dog.320w.jpg")
+	}
+	if result := config.VariantPath("/assets/cat.jpeg", 320); result != "/assets/cat.320w.jpeg" {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, "/assets/cat.320w.jpeg")
+	}
+}
+
+func TestImageConfigValidate(t *testing.T) {
+	if err := (ImageConfig{Format: "png", PngCompression: "best"}).Validate(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	err := ImageConfig{Format: "webp"}.Validate()
+	if err == nil || err.Error() != "Unknown image format: webp" {
+		t.Fatalf("Expected error for unknown format but got: %v", err)
+	}
+
+	err = ImageConfig{PngCompression: "lots"}.Validate()
+	if err == nil || err.Error() != "Unknown PNG compression: lots" {
+		t.Fatalf("Expected error for unknown compression but got: %v", err)
+	}
+}
+
+func TestResizeImage(t *testing.T) {
+	contents := makeTestImage(t, 100, 50)
+
+	for _, format := range []string{"png", "jpeg"} {
+		resized, err := ResizeImage(contents, 40, format, ImageConfig{Quality: 60})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		config, decodedFormat, err := image.DecodeConfig(bytes.NewReader(resized))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if config.Width != 40 || config.Height != 20 || decodedFormat != format {
+			t.Fatalf("Received:\n%v %vx%v\nExpected:\n%v 40x20", decodedFormat, config.Width, config.Height, format)
+		}
+	}
+
+	_, err := ResizeImage(contents, 40, "gif", ImageConfig{})
+	if err == nil || err.Error() != "Unknown image format: gif" {
+		t.Fatalf("Expected error for unknown format but got: %v", err)
+	}
+}
+
+func TestResizeImageCached(t *testing.T) {
+	temporaryDirectory, err := os.MkdirTemp("", "images")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.RemoveAll(temporaryDirectory)
+
+	sourcePath := path.Join(temporaryDirectory, "cat.png")
+	if err := os.WriteFile(sourcePath, makeTestImage(t, 100, 50), 0644); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	width, height, err := ImageSize(sourcePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if width != 100 || height != 50 {
+		t.Fatalf("Received:\n%vx%v\nExpected:\n100x50", width, height)
+	}
+
+	cacheDirectory := path.Join(temporaryDirectory, "cache")
+	resized, err := ResizeImageCached(cacheDirectory, sourcePath, 50, ImageConfig{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	entries, err := os.ReadDir(cacheDirectory)
+	if err != nil || len(entries) != 1 || path.Ext(entries[0].Name()) != ".png" {
+		t.Fatalf("Expected a single cached image but got: %v (%v)", entries, err)
+	}
+
+	// The cached image is used instead of resizing it again
+	cachePath := path.Join(cacheDirectory, entries[0].Name())
+	if err := os.WriteFile(cachePath, []byte("cached"), 0644); err != nil {
+		t.Fatalf("%v", err)
+	}
+	result, err := ResizeImageCached(cacheDirectory, sourcePath, 50, ImageConfig{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(result) != "cached" {
+		t.Fatalf("Expected the cached image but got %v bytes", len(result))
+	}
+
+	// Changing the config means resizing it again
+	result, err = ResizeImageCached(cacheDirectory, sourcePath, 50, ImageConfig{Format: "jpeg"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(result) == "cached" || bytes.Equal(result, resized) {
+		t.Fatalf("Expected the image to be resized again")
+	}
+}
+
+func TestPruneImageCache(t *testing.T) {
+	temporaryDirectory, err := os.MkdirTemp("", "images")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.RemoveAll(temporaryDirectory)
+
+	sourcePath := path.Join(temporaryDirectory, "cat.png")
+	if err := os.WriteFile(sourcePath, makeTestImage(t, 100, 50), 0644); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	cacheDirectory := path.Join(temporaryDirectory, "cache")
+	for _, width := range []int{20, 50} {
+		if _, err := ResizeImageCached(cacheDirectory, sourcePath, width, ImageConfig{}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	name, err := ImageCacheName(sourcePath, 50, ImageConfig{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	removed, err := PruneImageCache(cacheDirectory, map[string]bool{name: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if removed != 1 {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", removed, 1)
+	}
+
+	entries, err := os.ReadDir(cacheDirectory)
+	if err != nil || len(entries) != 1 || entries[0].Name() != name {
+		t.Fatalf("Expected only %v to be cached but got: %v (%v)", name, entries, err)
+	}
+
+	// Nothing is pruned if there is no cache
+	removed, err = PruneImageCache(path.Join(temporaryDirectory, "nope"), nil)
+	if err != nil || removed != 0 {
+		t.Fatalf("Expected nothing to be removed but got: %v (%v)", removed, err)
+	}
+}
+
+func TestImageAttributes(t *testing.T) {
+	markdown := newTestMarkdown(t, MarkdownConfig{
+		Images: func(destination string) map[string]string {
+			if destination != "/assets/cat.jpg" {
+				return nil
+			}
+			return map[string]string{"width": "800", "height": "600", "srcset": "/assets/cat.400w.jpg 400w, /assets/cat.jpg 800w"}
+		},
+	})
+
+	result := markdown.Render([]byte("![Cat](/assets/cat.jpg) ![Dog](/dog.jpg)"))
+	expected := `<p><img src="/assets/cat.jpg" alt="Cat" height="600" srcset="/assets/cat.400w.jpg 400w, /assets/cat.jpg 800w" width="800"> <img src="/dog.jpg" alt="Dog"></p>
+`
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+}
@@ -0,0 +1,206 @@
+package files
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Config for the attributes added to links pointing outside of the site,
+// which are only changed if at least one of them is set.
+type ExternalLinksConfig struct {
+	Rel    string
+	Target string
+	Class  string
+}
+
+// The kind of a heading anchor node.
+var KindHeadingAnchor = ast.NewNodeKind("HeadingAnchor")
+
+// A permalink to the heading it's in.
+type HeadingAnchor struct {
+	ast.BaseInline
+	Id string
+}
+
+func (node *HeadingAnchor) Kind() ast.NodeKind {
+	return KindHeadingAnchor
+}
+
+func (node *HeadingAnchor) Dump(source []byte, level int) {
+	ast.DumpHelper(node, source, level, map[string]string{"Id": node.Id}, nil)
+}
+
+// Transformer adding a permalink anchor to each heading.
+type headingAnchorTransformer struct{}
+
+func (t *headingAnchorTransformer) Transform(document *ast.Document, reader text.Reader, pc parser.Context) {
+	ast.Walk(document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := node.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+
+		if id, ok := heading.AttributeString("id"); ok {
+			if idBytes, ok := id.([]byte); ok && len(idBytes) > 0 {
+				heading.AppendChild(heading, &HeadingAnchor{Id: string(idBytes)})
+			}
+		}
+
+		return ast.WalkSkipChildren, nil
+	})
+}
+
+// Renderer for heading anchors.
+type headingAnchorRenderer struct{}
+
+func (r *headingAnchorRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindHeadingAnchor, r.renderHeadingAnchor)
+}
+
+func (r *headingAnchorRenderer) renderHeadingAnchor(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		fmt.Fprintf(w, ` <a class="heading-anchor" href="#%v" aria-label="Permalink">#</a>`, html.EscapeString(node.(*HeadingAnchor).Id))
+	}
+
+	return ast.WalkSkipChildren, nil
+}
+
+// Extension adding permalink anchors to headings.
+type headingAnchorExtension struct{}
+
+func (e *headingAnchorExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(util.Prioritized(&headingAnchorTransformer{}, 500)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(&headingAnchorRenderer{}, 500)))
+}
+
+// Check whether a link points outside of the site, i.e. to another host than
+// the one in the root URL.
+func isExternalLink(destination string, rootUrl string) bool {
+	link, err := url.Parse(destination)
+	if err != nil || link.Host == "" {
+		return false
+	}
+	if link.Scheme != "" && link.Scheme != "http" && link.Scheme != "https" {
+		return false
+	}
+
+	root, err := url.Parse(rootUrl)
+	if err != nil || root.Host == "" {
+		return true
+	}
+
+	return !strings.EqualFold(link.Host, root.Host)
+}
+
+// Transformer adding attributes to links pointing outside of the site.
+type externalLinkTransformer struct {
+	config  ExternalLinksConfig
+	rootUrl string
+}
+
+func (t *externalLinkTransformer) Transform(document *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+
+	ast.Walk(document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		var destination string
+		switch link := node.(type) {
+		case *ast.Link:
+			destination = string(link.Destination)
+		case *ast.AutoLink:
+			if link.AutoLinkType != ast.AutoLinkURL {
+				return ast.WalkContinue, nil
+			}
+			destination = string(link.URL(source))
+			if strings.HasPrefix(destination, "www.") {
+				destination = "http://" + destination
+			}
+		default:
+			return ast.WalkContinue, nil
+		}
+
+		if !isExternalLink(destination, t.rootUrl) {
+			return ast.WalkContinue, nil
+		}
+
+		if t.config.Rel != "" {
+			node.SetAttributeString("rel", []byte(t.config.Rel))
+		}
+		if t.config.Target != "" {
+			node.SetAttributeString("target", []byte(t.config.Target))
+		}
+		if t.config.Class != "" {
+			class := t.config.Class
+			if existing, ok := node.AttributeString("class"); ok {
+				if existingBytes, ok := existing.([]byte); ok && len(existingBytes) > 0 {
+					class = string(existingBytes) + " " + class
+				}
+			}
+			node.SetAttributeString("class", []byte(class))
+		}
+
+		return ast.WalkContinue, nil
+	})
+}
+
+// Extension adding attributes to links pointing outside of the site.
+type externalLinkExtension struct {
+	config  ExternalLinksConfig
+	rootUrl string
+}
+
+func (e *externalLinkExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(util.Prioritized(&externalLinkTransformer{e.config, e.rootUrl}, 500)))
+}
+
+// A function rewriting the destination of a link or image in a markdown
+// document, returning it as it is if it isn't changed.
+type LinkFunc func(destination string) string
+
+// Key of the function rewriting the links of a document in the parser context.
+var linkFuncKey = parser.NewContextKey()
+
+// Transformer rewriting the destinations of the links and images in a
+// document using the function in the parser context, if there is one.
+type linkTransformer struct{}
+
+func (t *linkTransformer) Transform(document *ast.Document, reader text.Reader, pc parser.Context) {
+	rewrite, ok := pc.Get(linkFuncKey).(LinkFunc)
+	if !ok || rewrite == nil {
+		return
+	}
+
+	ast.Walk(document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		switch link := node.(type) {
+		case *ast.Link:
+			link.Destination = []byte(rewrite(string(link.Destination)))
+		case *ast.Image:
+			link.Destination = []byte(rewrite(string(link.Destination)))
+		}
+
+		return ast.WalkContinue, nil
+	})
+}
+
+// Extension rewriting the destinations of links and images.
+type linkExtension struct{}
+
+func (e *linkExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(util.Prioritized(&linkTransformer{}, 1000)))
+}
@@ -0,0 +1,57 @@
+package files
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHeadingAnchors(t *testing.T) {
+	markdown := newTestMarkdown(t, MarkdownConfig{HeadingAnchors: true})
+
+	result, headings := markdown.RenderWithHeadings([]byte("# Hello *World*\n\nText\n\n## Next"))
+	expected := `<h1 id="hello-world">Hello <em>World</em> <a class="heading-anchor" href="#hello-world" aria-label="Permalink">#</a></h1>
+<p>Text</p>
+<h2 id="next">Next <a class="heading-anchor" href="#next" aria-label="Permalink">#</a></h2>
+`
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+
+	// The anchors aren't part of the heading titles
+	expectedHeadings := []Heading{{Level: 1, Id: "hello-world", Title: "Hello World"}, {Level: 2, Id: "next", Title: "Next"}}
+	if !reflect.DeepEqual(headings, expectedHeadings) {
+		t.Fatalf("Received:\n%+v\nExpected:\n%+v", headings, expectedHeadings)
+	}
+}
+
+func TestExternalLinks(t *testing.T) {
+	markdown := newTestMarkdown(t, MarkdownConfig{
+		Linkify:       true,
+		ExternalLinks: ExternalLinksConfig{Rel: "noopener", Target: "_blank", Class: "external"},
+		RootUrl:       "https://example.com",
+	})
+
+	result := markdown.Render([]byte("[Out](https://other.org/x), [in](https://example.com/y), [relative](/z), [mail](mailto:a@b.c) and www.other.org"))
+	expected := `<p><a href="https://other.org/x" rel="noopener" target="_blank" class="external">Out</a>, <a href="https://example.com/y">in</a>, <a href="/z">relative</a>, <a href="mailto:a@b.c">mail</a> and <a href="http://www.other.org" rel="noopener" target="_blank" class="external">www.other.org</a></p>
+`
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+
+	tests := map[string]bool{
+		"https://other.org":        true,
+		"//other.org/x":            true,
+		"https://EXAMPLE.com/page": false,
+		"/page":                    false,
+		"#heading":                 false,
+		"ftp://other.org":          false,
+	}
+	for destination, expected := range tests {
+		if result := isExternalLink(destination, "https://example.com"); result != expected {
+			t.Fatalf("Result for %v: %v\nExpected: %v", destination, result, expected)
+		}
+	}
+	if !isExternalLink("https://example.com", "") {
+		t.Fatalf("Expected links to be external without a root URL")
+	}
+}
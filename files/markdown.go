@@ -2,48 +2,234 @@ package files
 
 import (
 	"bytes"
+	"fmt"
 
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/styles"
 	"github.com/yuin/goldmark"
-	"github.com/yuin/goldmark-meta"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/extension"
 	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
 )
 
-// Parse markdown, rendering it to HTML and returning the metadata as a map.
-func ParseMarkdown(text []byte) (map[string]interface{}, string) {
-	markdown := goldmark.New(
-		goldmark.WithExtensions(
-			meta.Meta,
-			extension.Strikethrough,
-		),
-		goldmark.WithRendererOptions(
-			html.WithUnsafe(),
-		),
-	)
+// Config for the markdown renderer, enabling optional extensions.
+type MarkdownConfig struct {
+	Tables          bool
+	Footnotes       bool
+	TaskLists       bool `yaml:"task_lists"`
+	DefinitionLists bool `yaml:"definition_lists"`
+	Typographer     bool
+	Linkify         bool
+	HeadingIds      bool `yaml:"heading_ids"`
+	Safe            bool
+	Math            bool
+	HeadingAnchors  bool                `yaml:"heading_anchors"`
+	WikiLinks       bool                `yaml:"wiki_links"`
+	ExternalLinks   ExternalLinksConfig `yaml:"external_links"`
+	Highlight       HighlightConfig
+	Toc             bool                `yaml:"-"`
+	RootUrl         string              `yaml:"-"`
+	Callout         CalloutFunc         `yaml:"-"`
+	Images          ImageAttributesFunc `yaml:"-"`
+}
+
+// Config for the syntax highlighting of code blocks, which is enabled when a
+// style is set.
+type HighlightConfig struct {
+	Style       string
+	Classes     bool
+	LineNumbers bool `yaml:"line_numbers"`
+}
+
+// A heading in a markdown document.
+type Heading struct {
+	Level int
+	Id    string
+	Title string
+}
+
+// A markdown renderer with the extensions enabled in its config.
+type Markdown struct {
+	renderer goldmark.Markdown
+}
+
+// The markdown renderer used when nothing has been configured.
+var defaultMarkdown, _ = NewMarkdown(MarkdownConfig{})
+
+// Make a markdown renderer based on a config.
+func NewMarkdown(config MarkdownConfig) (*Markdown, error) {
+	extensions := []goldmark.Extender{extension.Strikethrough, &calloutExtension{config.Callout}, &linkExtension{}}
+	if config.Tables {
+		extensions = append(extensions, extension.Table)
+	}
+	if config.Footnotes {
+		extensions = append(extensions, extension.Footnote)
+	}
+	if config.TaskLists {
+		extensions = append(extensions, extension.TaskList)
+	}
+	if config.DefinitionLists {
+		extensions = append(extensions, extension.DefinitionList)
+	}
+	if config.Typographer {
+		extensions = append(extensions, extension.Typographer)
+	}
+	if config.Linkify {
+		extensions = append(extensions, extension.Linkify)
+	}
+	if config.HeadingAnchors {
+		extensions = append(extensions, &headingAnchorExtension{})
+	}
+	if config.WikiLinks {
+		extensions = append(extensions, &wikiLinkExtension{})
+	}
+	if config.ExternalLinks != (ExternalLinksConfig{}) {
+		extensions = append(extensions, &externalLinkExtension{config.ExternalLinks, config.RootUrl})
+	}
+	if config.Math {
+		extensions = append(extensions, &mathExtension{})
+	}
+	if config.Images != nil {
+		extensions = append(extensions, &imageExtension{config.Images})
+	}
+	if config.Highlight.Style != "" {
+		if _, ok := styles.Registry[config.Highlight.Style]; !ok {
+			return nil, fmt.Errorf("Unknown highlight style: %v", config.Highlight.Style)
+		}
+
+		extensions = append(extensions, highlighting.NewHighlighting(
+			highlighting.WithStyle(config.Highlight.Style),
+			highlighting.WithFormatOptions(
+				chromahtml.WithClasses(config.Highlight.Classes),
+				chromahtml.WithLineNumbers(config.Highlight.LineNumbers),
+			),
+		))
+	}
+
+	// Headings need an ID for the table of contents, anchors and wiki links
+	// to link to
+	parserOptions := []parser.Option{}
+	if config.HeadingIds || config.Toc || config.HeadingAnchors || config.WikiLinks {
+		parserOptions = append(parserOptions, parser.WithAutoHeadingID())
+	}
+
+	rendererOptions := []goldmark.Option{}
+	if !config.Safe {
+		rendererOptions = append(rendererOptions, goldmark.WithRendererOptions(html.WithUnsafe()))
+	}
+
+	renderer := goldmark.New(append(rendererOptions,
+		goldmark.WithExtensions(extensions...),
+		goldmark.WithParserOptions(parserOptions...),
+	)...)
+
+	return &Markdown{renderer}, nil
+}
+
+// Generate the stylesheet used when highlighting code blocks with classes.
+func HighlightStylesheet(config HighlightConfig) (string, error) {
+	style, ok := styles.Registry[config.Style]
+	if !ok {
+		return "", fmt.Errorf("Unknown highlight style: %v", config.Style)
+	}
 
 	var buf bytes.Buffer
-	context := parser.NewContext()
-	if err := markdown.Convert(text, &buf, parser.WithContext(context)); err != nil {
-		panic(err)
+	formatter := chromahtml.New(chromahtml.WithClasses(true), chromahtml.WithLineNumbers(config.LineNumbers))
+	if err := formatter.WriteCSS(&buf, style); err != nil {
+		return "", err
 	}
 
-	return meta.Get(context), buf.String()
+	return buf.String(), nil
+}
+
+// Get the plain text of a node and its descendants.
+func nodeText(node ast.Node, source []byte) string {
+	var buf bytes.Buffer
+	ast.Walk(node, func(child ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch child := child.(type) {
+		case *ast.Text:
+			buf.Write(child.Segment.Value(source))
+			if child.SoftLineBreak() || child.HardLineBreak() {
+				buf.WriteByte(' ')
+			}
+		case *ast.String:
+			buf.Write(child.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	return buf.String()
+}
+
+// Parse markdown, rendering it to HTML and returning the metadata from its
+// front matter as a map.
+func (markdown *Markdown) Parse(text []byte) (map[string]interface{}, string) {
+	metadata, content := ParseFrontMatter(text)
+
+	return metadata, markdown.Render(content)
 }
 
 // Render markdown to HTML.
-func RenderMarkdown(text []byte) string {
-	markdown := goldmark.New(
-		goldmark.WithExtensions(
-			extension.Strikethrough,
-		),
-	)
+func (markdown *Markdown) Render(text []byte) string {
+	html, _ := markdown.RenderWithHeadings(text)
 
-	var buf bytes.Buffer
+	return html
+}
+
+// Render markdown to HTML, returning the headings in the document as well.
+func (markdown *Markdown) RenderWithHeadings(source []byte) (string, []Heading) {
+	return markdown.RenderWithLinks(source, nil)
+}
+
+// Render markdown to HTML like RenderWithHeadings, rewriting the destinations
+// of the links and images in it using the given function.
+func (markdown *Markdown) RenderWithLinks(source []byte, links LinkFunc) (string, []Heading) {
 	context := parser.NewContext()
-	if err := markdown.Convert(text, &buf, parser.WithContext(context)); err != nil {
+	context.Set(linkFuncKey, links)
+	document := markdown.renderer.Parser().Parse(text.NewReader(source), parser.WithContext(context))
+
+	var headings []Heading
+	ast.Walk(document, func(node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if heading, ok := node.(*ast.Heading); ok && entering {
+			id, _ := heading.AttributeString("id")
+			idBytes, _ := id.([]byte)
+			headings = append(headings, Heading{
+				Level: heading.Level,
+				Id:    string(idBytes),
+				Title: nodeText(heading, source),
+			})
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+
+	var buf bytes.Buffer
+	if err := markdown.renderer.Renderer().Render(&buf, source, document); err != nil {
 		panic(err)
 	}
 
-	return buf.String()
+	return buf.String(), headings
+}
+
+// Parse markdown using the default renderer, rendering it to HTML and
+// returning the metadata from its front matter as a map.
+func ParseMarkdown(text []byte) (map[string]interface{}, string) {
+	return defaultMarkdown.Parse(text)
+}
+
+// Render markdown to HTML using the default renderer.
+func RenderMarkdown(text []byte) string {
+	return defaultMarkdown.Render(text)
+}
+
+// Render markdown to HTML using the default renderer, returning the headings
+// in the document as well.
+func RenderMarkdownWithHeadings(source []byte) (string, []Heading) {
+	return defaultMarkdown.RenderWithHeadings(source)
 }
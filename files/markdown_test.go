@@ -2,9 +2,20 @@ package files
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 )
 
+// Make a markdown renderer for a test, failing if the config is invalid.
+func newTestMarkdown(t *testing.T, config MarkdownConfig) *Markdown {
+	markdown, err := NewMarkdown(config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	return markdown
+}
+
 func TestParseMarkdown(t *testing.T) {
 	test := []byte(`---
 title: Test
@@ -63,3 +74,144 @@ func TestRenderMarkdown(t *testing.T) {
 		t.Fatalf("Expected: '%s'\nReceived: '%s'", expected, result)
 	}
 }
+
+func TestNewMarkdown(t *testing.T) {
+	text := []byte(`# Title
+
+| A | B |
+|---|---|
+| 1 | 2 |
+
+- [x] Done
+
+Term
+: Definition
+
+"Quoted" https://example.org<br>
+`)
+
+	result := RenderMarkdown(text)
+	expected := `<h1>Title</h1>
+<p>| A | B |
+|---|---|
+| 1 | 2 |</p>
+<ul>
+<li>[x] Done</li>
+</ul>
+<p>Term
+: Definition</p>
+<p>&quot;Quoted&quot; https://example.org<br></p>
+`
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+
+	markdown := newTestMarkdown(t, MarkdownConfig{
+		Tables:          true,
+		TaskLists:       true,
+		DefinitionLists: true,
+		Typographer:     true,
+		Linkify:         true,
+		HeadingIds:      true,
+		Safe:            true,
+	})
+
+	result = markdown.Render(text)
+	expected = `<h1 id="title">Title</h1>
+<table>
+<thead>
+<tr>
+<th>A</th>
+<th>B</th>
+</tr>
+</thead>
+<tbody>
+<tr>
+<td>1</td>
+<td>2</td>
+</tr>
+</tbody>
+</table>
+<ul>
+<li><input checked="" disabled="" type="checkbox"> Done</li>
+</ul>
+<dl>
+<dt>Term</dt>
+<dd>Definition</dd>
+</dl>
+<p>&ldquo;Quoted&rdquo; <a href="https://example.org">https://example.org</a><!-- raw HTML omitted --></p>
+`
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+
+	markdown = newTestMarkdown(t, MarkdownConfig{Footnotes: true})
+
+	result = markdown.Render([]byte("Text[^1]\n\n[^1]: Note"))
+	if !strings.Contains(result, `<div class="footnotes" role="doc-endnotes">`) {
+		t.Fatalf("Missing footnotes:\n%v", result)
+	}
+}
+
+func TestHighlightCodeBlocks(t *testing.T) {
+	text := []byte("```go {linenos=true hl_lines=[2]}\npackage main\nfunc main() {}\n```\n")
+
+	markdown := newTestMarkdown(t, MarkdownConfig{Highlight: HighlightConfig{Style: "monokai", Classes: true}})
+	result := markdown.Render(text)
+	for _, expected := range []string{`<pre class="chroma">`, `<span class="line hl">`, `<span class="ln">2</span>`, `<span class="kn">package</span>`} {
+		if !strings.Contains(result, expected) {
+			t.Fatalf("Missing %v in:\n%v", expected, result)
+		}
+	}
+
+	markdown = newTestMarkdown(t, MarkdownConfig{Highlight: HighlightConfig{Style: "monokai"}})
+	result = markdown.Render([]byte("```go\npackage main\n```\n"))
+	if !strings.Contains(result, `<span style="color:#f92672">package</span>`) {
+		t.Fatalf("Missing inline styles in:\n%v", result)
+	}
+
+	if _, err := NewMarkdown(MarkdownConfig{Highlight: HighlightConfig{Style: "nope"}}); err == nil {
+		t.Fatalf("Expected error but got nil")
+	}
+}
+
+func TestHighlightStylesheet(t *testing.T) {
+	result, err := HighlightStylesheet(HighlightConfig{Style: "monokai"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(result, ".chroma .kn {") {
+		t.Fatalf("Incorrect stylesheet:\n%v", result)
+	}
+
+	if _, err := HighlightStylesheet(HighlightConfig{Style: "nope"}); err == nil {
+		t.Fatalf("Expected error but got nil")
+	}
+}
+
+func TestNewMarkdownToc(t *testing.T) {
+	text := []byte("# Title\n\n## Title\n")
+
+	// Headings only get an ID when something needs to link to them
+	result, headings := RenderMarkdownWithHeadings(text)
+	expected := "<h1>Title</h1>\n<h2>Title</h2>\n"
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+	expectedHeadings := []Heading{{Level: 1, Title: "Title"}, {Level: 2, Title: "Title"}}
+	if !reflect.DeepEqual(headings, expectedHeadings) {
+		t.Fatalf("Received:\n%+v\nExpected:\n%+v", headings, expectedHeadings)
+	}
+
+	markdown := newTestMarkdown(t, MarkdownConfig{Toc: true})
+
+	result, headings = markdown.RenderWithHeadings(text)
+	expected = "<h1 id=\"title\">Title</h1>\n<h2 id=\"title-1\">Title</h2>\n"
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+	expectedHeadings = []Heading{{Level: 1, Id: "title", Title: "Title"}, {Level: 2, Id: "title-1", Title: "Title"}}
+	if !reflect.DeepEqual(headings, expectedHeadings) {
+		t.Fatalf("Received:\n%+v\nExpected:\n%+v", headings, expectedHeadings)
+	}
+}
@@ -0,0 +1,231 @@
+package files
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// The kind of a math node.
+var KindMath = ast.NewNodeKind("Math")
+
+// The kind of a math block node.
+var KindMathBlock = ast.NewNodeKind("MathBlock")
+
+// A formula inside a paragraph, e.g. `$x^2$` or `$$x^2$$`.
+type Math struct {
+	ast.BaseInline
+	Formula []byte
+	Display bool
+}
+
+func (node *Math) Kind() ast.NodeKind {
+	return KindMath
+}
+
+func (node *Math) Dump(source []byte, level int) {
+	ast.DumpHelper(node, source, level, map[string]string{"Formula": string(node.Formula)}, nil)
+}
+
+// A formula displayed as a block, surrounded by `$$` lines.
+type MathBlock struct {
+	ast.BaseBlock
+	Formula []byte
+}
+
+func (node *MathBlock) Kind() ast.NodeKind {
+	return KindMathBlock
+}
+
+func (node *MathBlock) IsRaw() bool {
+	return true
+}
+
+func (node *MathBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(node, source, level, map[string]string{"Formula": string(node.Formula)}, nil)
+}
+
+// Parser for formulas inside paragraphs.
+type mathInlineParser struct{}
+
+func (p *mathInlineParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (p *mathInlineParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+
+	delimiter := 1
+	if len(line) > 1 && line[1] == '$' {
+		delimiter = 2
+	}
+
+	// The formula can't start or end with a space, or be followed by a digit,
+	// so that prices such as "$5 or $10" are left alone
+	start := delimiter
+	if start >= len(line) || util.IsSpace(line[start]) || line[start] == '$' {
+		return nil
+	}
+
+	for end := start; end < len(line); end++ {
+		if line[end] == '\\' {
+			end++
+			continue
+		}
+		if line[end] != '$' {
+			continue
+		}
+		if delimiter == 2 {
+			if end+1 < len(line) && line[end+1] == '$' {
+				block.Advance(end + 2)
+				return &Math{Formula: copyBytes(line[start:end]), Display: true}
+			}
+			continue
+		}
+		if util.IsSpace(line[end-1]) || (end+1 < len(line) && line[end+1] >= '0' && line[end+1] <= '9') {
+			return nil
+		}
+
+		block.Advance(end + 1)
+		return &Math{Formula: copyBytes(line[start:end])}
+	}
+
+	return nil
+}
+
+func copyBytes(value []byte) []byte {
+	return append([]byte{}, value...)
+}
+
+// Parser for formulas displayed as a block.
+type mathBlockParser struct{}
+
+func (p *mathBlockParser) Trigger() []byte {
+	return []byte{'$'}
+}
+
+func (p *mathBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	trimmed := util.TrimRightSpace(util.TrimLeftSpace(line))
+	if !bytes.HasPrefix(trimmed, []byte("$$")) {
+		return nil, parser.NoChildren
+	}
+
+	// The whole formula can be on a single line, otherwise anything after the
+	// delimiter is part of a paragraph
+	node := &MathBlock{}
+	rest := trimmed[2:]
+	if len(rest) >= 2 && bytes.HasSuffix(rest, []byte("$$")) {
+		advanceLine(reader, line, segment)
+		node.Formula = copyBytes(rest[:len(rest)-2])
+		return node, parser.Close
+	}
+	if bytes.Contains(rest, []byte("$")) {
+		return nil, parser.NoChildren
+	}
+
+	advanceLine(reader, line, segment)
+	node.Formula = append(copyBytes(rest), '\n')
+	return node, parser.NoChildren
+}
+
+func (p *mathBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	line, segment := reader.PeekLine()
+	if line == nil {
+		return parser.Close
+	}
+
+	block := node.(*MathBlock)
+	trimmed := util.TrimRightSpace(line)
+	advanceLine(reader, line, segment)
+
+	if bytes.HasSuffix(trimmed, []byte("$$")) {
+		block.Formula = append(block.Formula, trimmed[:len(trimmed)-2]...)
+		return parser.Close
+	}
+
+	block.Formula = append(block.Formula, line...)
+	return parser.Continue | parser.NoChildren
+}
+
+// Advance to the end of the line, leaving the newline for the parser.
+func advanceLine(reader text.Reader, line []byte, segment text.Segment) {
+	newline := 0
+	if len(line) > 0 && line[len(line)-1] == '\n' {
+		newline = 1
+	}
+	reader.Advance(segment.Len() - newline)
+}
+
+func (p *mathBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {
+}
+
+func (p *mathBlockParser) CanInterruptParagraph() bool {
+	return true
+}
+
+func (p *mathBlockParser) CanAcceptIndentedLine() bool {
+	return false
+}
+
+// Renderer converting formulas to MathML.
+type mathRenderer struct{}
+
+func (r *mathRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindMath, r.renderMath)
+	reg.Register(KindMathBlock, r.renderMathBlock)
+}
+
+func (r *mathRenderer) renderMath(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		math := node.(*Math)
+		w.WriteString(renderFormula(string(math.Formula), math.Display))
+	}
+
+	return ast.WalkSkipChildren, nil
+}
+
+func (r *mathRenderer) renderMathBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		w.WriteString(renderFormula(string(bytes.TrimSpace(node.(*MathBlock).Formula)), true))
+		w.WriteString("\n")
+	}
+
+	return ast.WalkSkipChildren, nil
+}
+
+// Render a formula to MathML, falling back to showing its source along with
+// a warning if it uses something which isn't supported.
+func renderFormula(formula string, display bool) string {
+	mathml, err := LatexToMathML(formula, display)
+	if err == nil {
+		return mathml
+	}
+
+	logger := log.Default()
+	logger.Printf("Unable to render math: %v (%v)", formula, err)
+
+	if display {
+		return fmt.Sprintf(`<pre class="math math-error"><code>%v</code></pre>`, escapeMathSource(formula))
+	}
+
+	return fmt.Sprintf(`<code class="math math-error">%v</code>`, escapeMathSource(formula))
+}
+
+// Extension rendering LaTeX formulas in markdown to MathML.
+type mathExtension struct{}
+
+func (e *mathExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(util.Prioritized(&mathBlockParser{}, 690)),
+		parser.WithInlineParsers(util.Prioritized(&mathInlineParser{}, 150)),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(&mathRenderer{}, 500)))
+}
@@ -0,0 +1,76 @@
+package files
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLatexToMathML(t *testing.T) {
+	tests := map[string]string{
+		`x_i^2`:            `<msubsup><mi>x</mi><mi>i</mi><mn>2</mn></msubsup>`,
+		`a + 1.5`:          `<mi>a</mi><mo>+</mo><mn>1.5</mn>`,
+		`\frac{a}{b}`:      `<mfrac><mrow><mi>a</mi></mrow><mrow><mi>b</mi></mrow></mfrac>`,
+		`\sqrt{x}`:         `<msqrt><mrow><mi>x</mi></mrow></msqrt>`,
+		`\sqrt[3]{x}`:      `<mroot><mrow><mi>x</mi></mrow><mrow><mn>3</mn></mrow></mroot>`,
+		`\alpha \leq \pi`:  `<mi>α</mi><mo>≤</mo><mi>π</mi>`,
+		`\sin x`:           `<mi>sin</mi><mi>x</mi>`,
+		`\text{if } x<y`:   `<mtext>if </mtext><mi>x</mi><mo>&lt;</mo><mi>y</mi>`,
+		`\mathbb{R}`:       `<mi mathvariant="double-struck">R</mi>`,
+		`\hat{x}`:          `<mover accent="true"><mrow><mi>x</mi></mrow><mo>^</mo></mover>`,
+		`\left( x \right)`: `<mrow><mo stretchy="true">(</mo><mi>x</mi><mo stretchy="true">)</mo></mrow>`,
+		`\sum_{i=1}^n i`:   `<msubsup><mo>∑</mo><mrow><mi>i</mi><mo>=</mo><mn>1</mn></mrow><mi>n</mi></msubsup><mi>i</mi>`,
+	}
+
+	for source, expected := range tests {
+		result, err := LatexToMathML(source, false)
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v", source, err)
+		}
+		expected = `<math xmlns="http://www.w3.org/1998/Math/MathML"><semantics><mrow>` + expected + `</mrow><annotation encoding="application/x-tex">` + escapeMathSource(source) + `</annotation></semantics></math>`
+		if result != expected {
+			t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+		}
+	}
+
+	result, err := LatexToMathML(`\sum_{i=1}^n i`, true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(result, ` display="block"`) || !strings.Contains(result, `<munderover><mo>∑</mo>`) {
+		t.Fatalf("Result:\n%v\nExpected a block with limits above and below", result)
+	}
+
+	for _, source := range []string{`\begin{matrix} a \end{matrix}`, `\frac{a}`, `x_1_2`, `{x`, `x}`, `\unknown`} {
+		if _, err := LatexToMathML(source, false); err == nil {
+			t.Fatalf("Expected an error for: %v", source)
+		}
+	}
+}
+
+func TestRenderMath(t *testing.T) {
+	text := []byte(`Prices are $5 or $10, and $a_i + b_i$ is *math*.
+
+$$
+x^2
+$$
+
+$\begin{matrix} a \end{matrix}$
+`)
+
+	// Without math enabled the underscores are treated as emphasis
+	result := RenderMarkdown(text)
+	if strings.Contains(result, "<math") {
+		t.Fatalf("Result:\n%v\nExpected no math", result)
+	}
+
+	markdown := newTestMarkdown(t, MarkdownConfig{Math: true})
+
+	result = markdown.Render(text)
+	expected := `<p>Prices are $5 or $10, and <math xmlns="http://www.w3.org/1998/Math/MathML"><semantics><mrow><msub><mi>a</mi><mi>i</mi></msub><mo>+</mo><msub><mi>b</mi><mi>i</mi></msub></mrow><annotation encoding="application/x-tex">a_i + b_i</annotation></semantics></math> is <em>math</em>.</p>
+<math xmlns="http://www.w3.org/1998/Math/MathML" display="block"><semantics><mrow><msup><mi>x</mi><mn>2</mn></msup></mrow><annotation encoding="application/x-tex">x^2</annotation></semantics></math>
+<p><code class="math math-error">\begin&#123;matrix&#125; a \end&#123;matrix&#125;</code></p>
+`
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+}
@@ -0,0 +1,458 @@
+package files
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// Greek letters, which are identifiers.
+var latexGreek = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ",
+	"varepsilon": "ε", "zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ",
+	"iota": "ι", "kappa": "κ", "lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ",
+	"pi": "π", "varpi": "ϖ", "rho": "ρ", "varrho": "ϱ", "sigma": "σ",
+	"varsigma": "ς", "tau": "τ", "upsilon": "υ", "phi": "ϕ", "varphi": "φ",
+	"chi": "χ", "psi": "ψ", "omega": "ω", "Gamma": "Γ", "Delta": "Δ",
+	"Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ", "Pi": "Π", "Sigma": "Σ",
+	"Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ", "Omega": "Ω",
+	"ell": "ℓ", "hbar": "ℏ", "infty": "∞", "emptyset": "∅", "partial": "∂",
+	"nabla": "∇",
+}
+
+// Symbols which are operators.
+var latexOperators = map[string]string{
+	"times": "×", "cdot": "⋅", "div": "÷", "pm": "±", "mp": "∓", "ast": "∗",
+	"leq": "≤", "le": "≤", "geq": "≥", "ge": "≥", "neq": "≠", "ne": "≠",
+	"approx": "≈", "equiv": "≡", "sim": "∼", "simeq": "≃", "cong": "≅",
+	"propto": "∝", "ll": "≪", "gg": "≫", "to": "→", "rightarrow": "→",
+	"leftarrow": "←", "leftrightarrow": "↔", "Rightarrow": "⇒",
+	"Leftarrow": "⇐", "Leftrightarrow": "⇔", "implies": "⟹", "iff": "⟺",
+	"mapsto": "↦", "in": "∈", "notin": "∉", "ni": "∋", "subset": "⊂",
+	"subseteq": "⊆", "supset": "⊃", "supseteq": "⊇", "cup": "∪", "cap": "∩",
+	"setminus": "∖", "forall": "∀", "exists": "∃", "neg": "¬", "land": "∧",
+	"wedge": "∧", "lor": "∨", "vee": "∨", "oplus": "⊕", "otimes": "⊗",
+	"circ": "∘", "perp": "⊥", "parallel": "∥", "mid": "∣", "ldots": "…",
+	"cdots": "⋯", "vdots": "⋮", "ddots": "⋱", "dots": "…", "sum": "∑",
+	"prod": "∏", "coprod": "∐", "int": "∫", "iint": "∬", "iiint": "∭",
+	"oint": "∮", "bigcup": "⋃", "bigcap": "⋂", "langle": "⟨", "rangle": "⟩",
+	"lfloor": "⌊", "rfloor": "⌋", "lceil": "⌈", "rceil": "⌉", "vert": "|",
+	"Vert": "‖", "{": "{", "}": "}", "|": "‖", "%": "%", "$": "$", "&": "&",
+	"#": "#", "_": "_",
+}
+
+// Named functions, which are shown upright.
+var latexFunctions = map[string]bool{
+	"sin": true, "cos": true, "tan": true, "cot": true, "sec": true, "csc": true,
+	"arcsin": true, "arccos": true, "arctan": true, "sinh": true, "cosh": true,
+	"tanh": true, "log": true, "ln": true, "lg": true, "exp": true, "lim": true,
+	"max": true, "min": true, "sup": true, "inf": true, "det": true, "dim": true,
+	"ker": true, "gcd": true, "deg": true, "arg": true, "Pr": true,
+}
+
+// Operators and functions with limits placed above and below them when
+// displayed as a block.
+var latexLimits = map[string]bool{
+	"sum": true, "prod": true, "coprod": true, "bigcup": true, "bigcap": true,
+	"lim": true, "max": true, "min": true, "sup": true, "inf": true,
+}
+
+// Spacing commands and their widths.
+var latexSpaces = map[string]string{
+	",": "0.167em", ":": "0.222em", ";": "0.278em", " ": "0.333em",
+	"quad": "1em", "qquad": "2em", "!": "-0.167em",
+}
+
+// Accents placed over their argument.
+var latexAccents = map[string]string{
+	"hat": "^", "widehat": "^", "bar": "¯", "overline": "¯", "vec": "→",
+	"dot": "˙", "ddot": "¨", "tilde": "~", "widetilde": "~",
+}
+
+// Font commands and their MathML variants.
+var latexFonts = map[string]string{
+	"mathbf": "bold", "mathit": "italic", "mathrm": "normal",
+	"mathbb": "double-struck", "mathcal": "script", "mathsf": "sans-serif",
+	"mathtt": "monospace", "operatorname": "normal",
+}
+
+// Characters which are operators.
+const latexOperatorCharacters = "+-=<>,;:!()[]|/*'.?"
+
+// A parser converting a LaTeX formula to MathML.
+type latexParser struct {
+	source  []rune
+	pos     int
+	display bool
+}
+
+// A part of the formula, along with the command it came from (used to
+// determine how to place limits).
+type mathAtom struct {
+	markup  string
+	command string
+}
+
+// Convert a LaTeX formula to MathML, returning an error for anything which
+// isn't supported.
+func LatexToMathML(source string, display bool) (string, error) {
+	parser := &latexParser{source: []rune(source), display: display}
+
+	content, err := parser.parseSequence(false)
+	if err != nil {
+		return "", err
+	}
+	if parser.pos < len(parser.source) {
+		return "", fmt.Errorf("Unexpected %q", string(parser.source[parser.pos]))
+	}
+
+	displayAttribute := ""
+	if display {
+		displayAttribute = ` display="block"`
+	}
+
+	return fmt.Sprintf(`<math xmlns="http://www.w3.org/1998/Math/MathML"%v><semantics><mrow>%v</mrow><annotation encoding="application/x-tex">%v</annotation></semantics></math>`,
+		displayAttribute, content, escapeMathSource(source)), nil
+}
+
+// Escape the source of a formula for use in HTML, including braces so that
+// they aren't mistaken for template tags.
+func escapeMathSource(source string) string {
+	return strings.NewReplacer("{", "&#123;", "}", "&#125;").Replace(html.EscapeString(source))
+}
+
+func (p *latexParser) skipSpaces() {
+	for p.pos < len(p.source) && unicode.IsSpace(p.source[p.pos]) {
+		p.pos++
+	}
+}
+
+func (p *latexParser) peek() (rune, bool) {
+	p.skipSpaces()
+	if p.pos >= len(p.source) {
+		return 0, false
+	}
+
+	return p.source[p.pos], true
+}
+
+// Parse atoms until the end of the formula or group, or a \right command.
+func (p *latexParser) parseSequence(inGroup bool) (string, error) {
+	var builder strings.Builder
+
+	for {
+		char, ok := p.peek()
+		if !ok {
+			if inGroup {
+				return "", fmt.Errorf("Missing closing brace")
+			}
+			break
+		}
+		if char == '}' {
+			if !inGroup {
+				return "", fmt.Errorf("Unexpected closing brace")
+			}
+			break
+		}
+		if p.hasCommand("right") {
+			break
+		}
+
+		atom, err := p.parseScripts()
+		if err != nil {
+			return "", err
+		}
+		builder.WriteString(atom.markup)
+	}
+
+	return builder.String(), nil
+}
+
+// Check whether the next command is the given one.
+func (p *latexParser) hasCommand(name string) bool {
+	end := p.pos + 1 + len(name)
+	if p.pos >= len(p.source) || p.source[p.pos] != '\\' || end > len(p.source) {
+		return false
+	}
+	if string(p.source[p.pos+1:end]) != name {
+		return false
+	}
+
+	return end == len(p.source) || !unicode.IsLetter(p.source[end])
+}
+
+// Parse an atom along with any subscript and superscript.
+func (p *latexParser) parseScripts() (mathAtom, error) {
+	base, err := p.parseAtom()
+	if err != nil {
+		return base, err
+	}
+
+	var sub, sup string
+	for {
+		char, ok := p.peek()
+		if !ok || (char != '_' && char != '^') {
+			break
+		}
+		p.pos++
+
+		script, err := p.parseAtom()
+		if err != nil {
+			return base, err
+		}
+		if char == '_' {
+			if sub != "" {
+				return base, fmt.Errorf("Double subscript")
+			}
+			sub = wrapRow(script.markup)
+		} else {
+			if sup != "" {
+				return base, fmt.Errorf("Double superscript")
+			}
+			sup = wrapRow(script.markup)
+		}
+	}
+
+	if sub == "" && sup == "" {
+		return base, nil
+	}
+
+	base.markup = wrapRow(base.markup)
+	limits := p.display && latexLimits[base.command]
+	switch {
+	case sub != "" && sup != "" && limits:
+		base.markup = fmt.Sprintf("<munderover>%v%v%v</munderover>", base.markup, sub, sup)
+	case sub != "" && sup != "":
+		base.markup = fmt.Sprintf("<msubsup>%v%v%v</msubsup>", base.markup, sub, sup)
+	case sub != "" && limits:
+		base.markup = fmt.Sprintf("<munder>%v%v</munder>", base.markup, sub)
+	case sub != "":
+		base.markup = fmt.Sprintf("<msub>%v%v</msub>", base.markup, sub)
+	case sup != "" && limits:
+		base.markup = fmt.Sprintf("<mover>%v%v</mover>", base.markup, sup)
+	case sup != "":
+		base.markup = fmt.Sprintf("<msup>%v%v</msup>", base.markup, sup)
+	}
+
+	return base, nil
+}
+
+// Make sure that the markup is a single element, wrapping it in an empty
+// row if there is nothing.
+func wrapRow(markup string) string {
+	if markup == "" {
+		return "<mrow></mrow>"
+	}
+
+	return markup
+}
+
+// Parse a required argument of a command.
+func (p *latexParser) parseArgument(command string) (string, error) {
+	if _, ok := p.peek(); !ok {
+		return "", fmt.Errorf("Missing argument for \\%v", command)
+	}
+
+	atom, err := p.parseAtom()
+	if err != nil {
+		return "", err
+	}
+
+	return wrapRow(atom.markup), nil
+}
+
+// Read the raw text of a braced argument.
+func (p *latexParser) readText(command string) (string, error) {
+	char, ok := p.peek()
+	if !ok || char != '{' {
+		return "", fmt.Errorf("Missing argument for \\%v", command)
+	}
+
+	end := p.pos + 1
+	for depth := 1; end < len(p.source); end++ {
+		if p.source[end] == '{' {
+			depth++
+		} else if p.source[end] == '}' {
+			depth--
+			if depth == 0 {
+				break
+			}
+		}
+	}
+	if end >= len(p.source) {
+		return "", fmt.Errorf("Missing closing brace")
+	}
+
+	text := string(p.source[p.pos+1 : end])
+	p.pos = end + 1
+
+	return text, nil
+}
+
+// Parse a single atom: a group, a command, a number, an identifier or an
+// operator.
+func (p *latexParser) parseAtom() (mathAtom, error) {
+	char, ok := p.peek()
+	if !ok {
+		return mathAtom{}, fmt.Errorf("Unexpected end of formula")
+	}
+
+	switch {
+	case char == '{':
+		p.pos++
+		content, err := p.parseSequence(true)
+		if err != nil {
+			return mathAtom{}, err
+		}
+		p.pos++
+		return mathAtom{markup: "<mrow>" + content + "</mrow>"}, nil
+	case char == '\\':
+		return p.parseCommand()
+	case unicode.IsDigit(char):
+		start := p.pos
+		for p.pos < len(p.source) && (unicode.IsDigit(p.source[p.pos]) || (p.source[p.pos] == '.' && p.pos+1 < len(p.source) && unicode.IsDigit(p.source[p.pos+1]))) {
+			p.pos++
+		}
+		return mathAtom{markup: "<mn>" + string(p.source[start:p.pos]) + "</mn>"}, nil
+	case unicode.IsLetter(char):
+		p.pos++
+		return mathAtom{markup: "<mi>" + html.EscapeString(string(char)) + "</mi>"}, nil
+	case char == '\'':
+		p.pos++
+		return mathAtom{markup: "<mo>′</mo>"}, nil
+	case strings.ContainsRune(latexOperatorCharacters, char):
+		p.pos++
+		return mathAtom{markup: "<mo>" + html.EscapeString(string(char)) + "</mo>"}, nil
+	}
+
+	return mathAtom{}, fmt.Errorf("Unsupported character %q", string(char))
+}
+
+// Parse a command, e.g. "\frac{a}{b}".
+func (p *latexParser) parseCommand() (mathAtom, error) {
+	p.pos++
+	if p.pos >= len(p.source) {
+		return mathAtom{}, fmt.Errorf("Unexpected end of formula")
+	}
+
+	start := p.pos
+	if unicode.IsLetter(p.source[p.pos]) {
+		for p.pos < len(p.source) && unicode.IsLetter(p.source[p.pos]) {
+			p.pos++
+		}
+	} else {
+		p.pos++
+	}
+	name := string(p.source[start:p.pos])
+
+	if val, ok := latexGreek[name]; ok {
+		return mathAtom{markup: "<mi>" + val + "</mi>", command: name}, nil
+	}
+	if val, ok := latexOperators[name]; ok {
+		return mathAtom{markup: "<mo>" + html.EscapeString(val) + "</mo>", command: name}, nil
+	}
+	if latexFunctions[name] {
+		return mathAtom{markup: "<mi>" + name + "</mi>", command: name}, nil
+	}
+	if width, ok := latexSpaces[name]; ok {
+		return mathAtom{markup: fmt.Sprintf(`<mspace width="%v"/>`, width)}, nil
+	}
+	if accent, ok := latexAccents[name]; ok {
+		argument, err := p.parseArgument(name)
+		if err != nil {
+			return mathAtom{}, err
+		}
+		return mathAtom{markup: fmt.Sprintf(`<mover accent="true">%v<mo>%v</mo></mover>`, argument, html.EscapeString(accent))}, nil
+	}
+	if variant, ok := latexFonts[name]; ok {
+		text, err := p.readText(name)
+		if err != nil {
+			return mathAtom{}, err
+		}
+		for _, char := range text {
+			if !unicode.IsLetter(char) && !unicode.IsDigit(char) && char != ' ' {
+				return mathAtom{}, fmt.Errorf("Unsupported content in \\%v: %v", name, text)
+			}
+		}
+		return mathAtom{markup: fmt.Sprintf(`<mi mathvariant="%v">%v</mi>`, variant, html.EscapeString(text))}, nil
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac":
+		numerator, err := p.parseArgument(name)
+		if err != nil {
+			return mathAtom{}, err
+		}
+		denominator, err := p.parseArgument(name)
+		if err != nil {
+			return mathAtom{}, err
+		}
+		return mathAtom{markup: "<mfrac>" + numerator + denominator + "</mfrac>"}, nil
+	case "sqrt":
+		if char, ok := p.peek(); ok && char == '[' {
+			end := p.pos
+			for end < len(p.source) && p.source[end] != ']' {
+				end++
+			}
+			if end >= len(p.source) {
+				return mathAtom{}, fmt.Errorf("Missing closing bracket")
+			}
+			indexParser := &latexParser{source: p.source[p.pos+1 : end]}
+			index, err := indexParser.parseSequence(false)
+			if err != nil {
+				return mathAtom{}, err
+			}
+			p.pos = end + 1
+			radicand, err := p.parseArgument(name)
+			if err != nil {
+				return mathAtom{}, err
+			}
+			return mathAtom{markup: "<mroot>" + radicand + "<mrow>" + index + "</mrow></mroot>"}, nil
+		}
+		radicand, err := p.parseArgument(name)
+		if err != nil {
+			return mathAtom{}, err
+		}
+		return mathAtom{markup: "<msqrt>" + radicand + "</msqrt>"}, nil
+	case "text", "textrm", "mbox":
+		text, err := p.readText(name)
+		if err != nil {
+			return mathAtom{}, err
+		}
+		return mathAtom{markup: "<mtext>" + html.EscapeString(text) + "</mtext>"}, nil
+	case "left", "right", "big", "Big", "bigg", "Bigg", "bigl", "bigr", "Bigl", "Bigr":
+		char, ok := p.peek()
+		if !ok {
+			return mathAtom{}, fmt.Errorf("Missing delimiter for \\%v", name)
+		}
+		if char == '.' {
+			p.pos++
+			return mathAtom{}, nil
+		}
+		delimiter, err := p.parseAtom()
+		if err != nil {
+			return mathAtom{}, err
+		}
+		if !strings.HasPrefix(delimiter.markup, "<mo>") {
+			return mathAtom{}, fmt.Errorf("Invalid delimiter for \\%v", name)
+		}
+		if name == "left" {
+			content, err := p.parseSequence(false)
+			if err != nil {
+				return mathAtom{}, err
+			}
+			if !p.hasCommand("right") {
+				return mathAtom{}, fmt.Errorf("Missing \\right")
+			}
+			closing, err := p.parseCommand()
+			if err != nil {
+				return mathAtom{}, err
+			}
+			return mathAtom{markup: "<mrow>" + strings.Replace(delimiter.markup, "<mo>", `<mo stretchy="true">`, 1) + content + closing.markup + "</mrow>"}, nil
+		}
+		return mathAtom{markup: strings.Replace(delimiter.markup, "<mo>", `<mo stretchy="true">`, 1)}, nil
+	}
+
+	return mathAtom{}, fmt.Errorf("Unsupported command \\%v", name)
+}
@@ -0,0 +1,123 @@
+package files
+
+import (
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/tdewolff/minify/v2"
+	"github.com/tdewolff/minify/v2/css"
+	"github.com/tdewolff/minify/v2/html"
+	"github.com/tdewolff/minify/v2/js"
+	"github.com/tdewolff/minify/v2/svg"
+	"github.com/tdewolff/minify/v2/xml"
+)
+
+// The types of files which can be minified, based on their extension.
+var minifyTypes = map[string]string{
+	".html": "html",
+	".css":  "css",
+	".js":   "js",
+	".mjs":  "js",
+	".svg":  "svg",
+	".xml":  "xml",
+	".rss":  "xml",
+	".atom": "xml",
+}
+
+// The media types used when minifying each type of file.
+var minifyMediaTypes = map[string]string{
+	"html": "text/html",
+	"css":  "text/css",
+	"js":   "application/javascript",
+	"svg":  "image/svg+xml",
+	"xml":  "text/xml",
+}
+
+// A minifier for the files written when building a site, keeping track of
+// the size of each type of file before and after minifying.
+type Minifier struct {
+	minifier *minify.M
+	Original map[string]int
+	Minified map[string]int
+}
+
+// Make a minifier for HTML, CSS, JS, SVG and XML files.
+func NewMinifier() *Minifier {
+	minifier := minify.New()
+	minifier.Add("text/html", &html.Minifier{KeepDocumentTags: true, KeepEndTags: true})
+	minifier.AddFunc("text/css", css.Minify)
+	minifier.AddFunc("application/javascript", js.Minify)
+	minifier.AddFunc("image/svg+xml", svg.Minify)
+	minifier.AddFunc("text/xml", xml.Minify)
+
+	return &Minifier{
+		minifier: minifier,
+		Original: map[string]int{},
+		Minified: map[string]int{},
+	}
+}
+
+// Get the type of a file which can be minified, or nothing if it can't.
+func MinifyType(filePath string) string {
+	return minifyTypes[strings.ToLower(path.Ext(filePath))]
+}
+
+// Minify the contents of a file of the given type ("html", "css", "js",
+// "svg" or "xml"), returning the contents as they are if it can't be.
+func (m *Minifier) Minify(fileType string, contents []byte) ([]byte, error) {
+	mediaType, ok := minifyMediaTypes[fileType]
+	if !ok {
+		return contents, nil
+	}
+
+	minified, err := m.minifier.Bytes(mediaType, contents)
+	if err != nil {
+		return contents, err
+	}
+
+	m.Original[fileType] += len(contents)
+	m.Minified[fileType] += len(minified)
+
+	return minified, nil
+}
+
+// Write a file of the given type, minifying its contents. The contents are
+// written as they are if they can't be minified.
+func (m *Minifier) WriteFile(fileType string, targetFilePath string, contents string) error {
+	minified, err := m.Minify(fileType, []byte(contents))
+	if err != nil {
+		logger := log.Default()
+		logger.Printf("Unable to minify %v: %v", targetFilePath, err)
+	}
+
+	return WriteFile(targetFilePath, string(minified))
+}
+
+// Copy a file from a source to a destination, minifying it if it's of a type
+// which can be minified.
+func (m *Minifier) CopyFile(source string, destination string) error {
+	fileType := MinifyType(source)
+	if fileType == "" {
+		return CopyFile(source, destination)
+	}
+
+	contents, err := os.ReadFile(source)
+	if err != nil {
+		return err
+	}
+
+	minified, err := m.Minify(fileType, contents)
+	if err != nil {
+		logger := log.Default()
+		logger.Printf("Unable to minify %v: %v", source, err)
+	}
+
+	return os.WriteFile(destination, minified, 0644)
+}
+
+// Copy a directory, minifying the files which can be minified.
+func (m *Minifier) CopyDirectory(sourceDirectory string, targetDirectory string) (int, error) {
+	return copyDirectory(sourceDirectory, targetDirectory, m.CopyFile)
+}
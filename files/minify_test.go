@@ -0,0 +1,75 @@
+package files
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestMinify(t *testing.T) {
+	minifier := NewMinifier()
+
+	tests := []struct {
+		fileType string
+		input    string
+		expected string
+	}{
+		{"html", "<html>\n  <body>\n    <p>  Hello   there </p>\n  </body>\n</html>\n", "<html><body><p>Hello there</p></body></html>"},
+		{"css", "body {\n  color : red ;\n}\n", "body{color:red}"},
+		{"js", "var  x = 1 ;\n// Comment\n", "var x=1"},
+		{"xml", "<rss>\n  <channel>\n    <title>Feed</title>\n  </channel>\n</rss>\n", "<rss><channel><title>Feed</title></channel></rss>"},
+		{"png", "not minified", "not minified"},
+	}
+
+	for _, test := range tests {
+		result, err := minifier.Minify(test.fileType, []byte(test.input))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if string(result) != test.expected {
+			t.Fatalf("Result:\n%v\nExpected:\n%v", string(result), test.expected)
+		}
+	}
+
+	if minifier.Original["css"] != 25 || minifier.Minified["css"] != 15 {
+		t.Fatalf("Incorrect sizes for css: %v => %v", minifier.Original["css"], minifier.Minified["css"])
+	}
+	if _, ok := minifier.Original["png"]; ok {
+		t.Fatalf("Expected no sizes for files which aren't minified")
+	}
+}
+
+func TestMinifierCopyDirectory(t *testing.T) {
+	temporaryDirectory, err := os.MkdirTemp("", "minify")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.RemoveAll(temporaryDirectory)
+
+	sourceDirectory := path.Join(temporaryDirectory, "assets")
+	os.MkdirAll(path.Join(sourceDirectory, "css"), 0755)
+	os.WriteFile(path.Join(sourceDirectory, "css", "style.css"), []byte("p {\n  margin : 0 ;\n}\n"), 0644)
+	os.WriteFile(path.Join(sourceDirectory, "notes.txt"), []byte("Some  notes\n"), 0644)
+
+	count, err := NewMinifier().CopyDirectory(sourceDirectory, path.Join(temporaryDirectory, "build"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("Result: %v\nExpected: 2", count)
+	}
+
+	expected := map[string]string{
+		"build/assets/css/style.css": "p{margin:0}",
+		"build/assets/notes.txt":     "Some  notes\n",
+	}
+	for filePath, contents := range expected {
+		result, err := os.ReadFile(path.Join(temporaryDirectory, filePath))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if string(result) != contents {
+			t.Fatalf("Result:\n%v\nExpected:\n%v", string(result), contents)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package files
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// The scheme of the placeholder URL which wiki links are rendered with until
+// their targets are resolved.
+const WikiLinkScheme = "wikilink:"
+
+// The kind of a wiki link node.
+var KindWikiLink = ast.NewNodeKind("WikiLink")
+
+// A link to another page or post by its name, e.g. `[[Some Page]]` or
+// `[[posts/first-post|label]]`.
+type WikiLink struct {
+	ast.BaseInline
+	Target string
+	Label  string
+}
+
+func (node *WikiLink) Kind() ast.NodeKind {
+	return KindWikiLink
+}
+
+func (node *WikiLink) Dump(source []byte, level int) {
+	ast.DumpHelper(node, source, level, map[string]string{"Target": node.Target, "Label": node.Label}, nil)
+}
+
+// Parser for wiki links.
+type wikiLinkParser struct{}
+
+func (p *wikiLinkParser) Trigger() []byte {
+	return []byte{'['}
+}
+
+func (p *wikiLinkParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	if !bytes.HasPrefix(line, []byte("[[")) {
+		return nil
+	}
+
+	end := bytes.Index(line, []byte("]]"))
+	if end < 0 {
+		return nil
+	}
+
+	content := line[2:end]
+	if bytes.ContainsAny(content, "[]") {
+		return nil
+	}
+
+	target, label, hasLabel := bytes.Cut(content, []byte("|"))
+	target = bytes.TrimSpace(target)
+	label = bytes.TrimSpace(label)
+	if len(target) == 0 {
+		return nil
+	}
+	if !hasLabel || len(label) == 0 {
+		label = target
+	}
+
+	block.Advance(end + 2)
+
+	return &WikiLink{Target: string(target), Label: string(label)}
+}
+
+// Renderer for wiki links, which are given a placeholder URL to be replaced
+// once all the pages and posts are known.
+type wikiLinkRenderer struct{}
+
+func (r *wikiLinkRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindWikiLink, r.renderWikiLink)
+}
+
+func (r *wikiLinkRenderer) renderWikiLink(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		link := node.(*WikiLink)
+		fmt.Fprintf(w, `<a class="wikilink" href="%v%v">%v</a>`, WikiLinkScheme, html.EscapeString(link.Target), html.EscapeString(link.Label))
+	}
+
+	return ast.WalkSkipChildren, nil
+}
+
+// Extension parsing wiki links.
+type wikiLinkExtension struct{}
+
+func (e *wikiLinkExtension) Extend(m goldmark.Markdown) {
+	// The parser needs to run before the one for regular links
+	m.Parser().AddOptions(parser.WithInlineParsers(util.Prioritized(&wikiLinkParser{}, 199)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(&wikiLinkRenderer{}, 500)))
+}
@@ -0,0 +1,23 @@
+package files
+
+import (
+	"testing"
+)
+
+func TestRenderWikiLinks(t *testing.T) {
+	// Wiki links are left as they are unless enabled
+	result := RenderMarkdown([]byte("See [[Some Page]]."))
+	if result != "<p>See [[Some Page]].</p>\n" {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, "<p>See [[Some Page]].</p>\n")
+	}
+
+	markdown := newTestMarkdown(t, MarkdownConfig{WikiLinks: true})
+
+	result = markdown.Render([]byte("See [[Some Page]], [[posts/first-post | the <first> post]] and [a link](/x).\n\n[[ ]] and [[a]b]] are left alone."))
+	expected := `<p>See <a class="wikilink" href="wikilink:Some Page">Some Page</a>, <a class="wikilink" href="wikilink:posts/first-post">the &lt;first&gt; post</a> and <a href="/x">a link</a>.</p>
+<p>[[ ]] and [[a]b]] are left alone.</p>
+`
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+}
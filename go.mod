@@ -5,14 +5,20 @@ go 1.21.5
 require github.com/spf13/cobra v1.8.1
 
 require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/alecthomas/chroma/v2 v2.14.0
 	github.com/cbroglie/mustache v1.4.0
 	github.com/gorilla/feeds v1.2.0
+	github.com/tdewolff/minify/v2 v2.20.37
 	github.com/yuin/goldmark v1.7.4
-	github.com/yuin/goldmark-meta v1.1.0
+	github.com/yuin/goldmark-highlighting/v2 v2.0.0-20230729083705-37449abec8cc
+	golang.org/x/image v0.23.0
 	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
+	github.com/dlclark/regexp2 v1.11.0 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
+	github.com/tdewolff/parse/v2 v2.7.15 // indirect
 )
@@ -0,0 +1,212 @@
+package site
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/michaelenger/brage/files"
+)
+
+// Name of the stylesheet generated when highlighting code using classes.
+const highlightStylesheetName = "highlight.css"
+
+// An asset of the site, along with the path it's available at when the site
+// is built. Bundles are made out of the files they are concatenated from
+// rather than a single source, and generated assets (e.g. resized images) are
+// made by a function.
+type Asset struct {
+	Source   string
+	Bundle   []string
+	Generate func() ([]byte, error)
+	Path     string
+}
+
+// Whether the asset is a file which can be copied as it is.
+func (asset Asset) IsFile() bool {
+	return len(asset.Bundle) == 0 && asset.Generate == nil
+}
+
+// Get the contents of the asset, concatenating the files of a bundle or
+// generating it.
+func (asset Asset) Contents() ([]byte, error) {
+	if asset.Generate != nil {
+		return asset.Generate()
+	}
+	if len(asset.Bundle) == 0 {
+		return os.ReadFile(asset.Source)
+	}
+
+	var buf bytes.Buffer
+	for _, source := range asset.Bundle {
+		contents, err := os.ReadFile(source)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(contents)
+		if len(contents) > 0 && contents[len(contents)-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Load the assets from the given directories, with assets in later
+// directories replacing those in earlier ones, along with the bundles made out
+// of them. The assets are keyed by their path inside the assets directory and
+// their contents are hashed into their path when fingerprinting.
+func loadAssets(directories []string, bundles map[string][]string, fingerprint bool) (map[string]Asset, error) {
+	assets := map[string]Asset{}
+
+	for _, directory := range directories {
+		assetsDirectory := path.Join(directory, "assets")
+		if fileInfo, err := os.Stat(assetsDirectory); err != nil || !fileInfo.IsDir() {
+			continue
+		}
+
+		err := filepath.WalkDir(assetsDirectory, func(filePath string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+
+			name, err := filepath.Rel(assetsDirectory, filePath)
+			if err != nil {
+				return err
+			}
+			name = filepath.ToSlash(name)
+
+			assets[name] = Asset{Source: filePath, Path: path.Join("/assets", name)}
+			return nil
+		})
+		if err != nil {
+			return assets, err
+		}
+	}
+
+	// Bundles are made out of files only, not other bundles
+	bundleAssets := map[string]Asset{}
+	for name, sources := range bundles {
+		name = assetName(name)
+		bundle := Asset{Path: path.Join("/assets", name)}
+		for _, source := range sources {
+			asset, ok := assets[assetName(source)]
+			if !ok {
+				return assets, fmt.Errorf("Unknown asset in bundle %v: %v", name, source)
+			}
+			bundle.Bundle = append(bundle.Bundle, asset.Source)
+		}
+		bundleAssets[name] = bundle
+	}
+	for name, bundle := range bundleAssets {
+		assets[name] = bundle
+	}
+
+	if fingerprint {
+		for name, asset := range assets {
+			contents, err := asset.Contents()
+			if err != nil {
+				return assets, err
+			}
+			asset.Path = files.FingerprintPath(asset.Path, contents)
+			assets[name] = asset
+		}
+	}
+
+	return assets, nil
+}
+
+// Add the assets generated from the config to the site: the stylesheet for
+// highlighting code using classes, and the resized variants of images.
+func (site Site) addGeneratedAssets() error {
+	if highlight := site.Config.Markdown.Highlight; highlight.Style != "" && highlight.Classes {
+		stylesheet, err := files.HighlightStylesheet(highlight)
+		if err != nil {
+			return err
+		}
+
+		asset := Asset{
+			Generate: func() ([]byte, error) { return []byte(stylesheet), nil },
+			Path:     path.Join("/assets", highlightStylesheetName),
+		}
+		if site.Config.Fingerprint {
+			asset.Path = files.FingerprintPath(asset.Path, []byte(stylesheet))
+		}
+		site.Assets[highlightStylesheetName] = asset
+	}
+
+	// The variants of fingerprinted images have the fingerprint of the image
+	// in their path
+	for name, image := range site.Images {
+		for _, variant := range image.Variants {
+			variant := variant
+			site.Assets[assetName(site.Config.Images.VariantPath(path.Join("/assets", name), variant.Width))] = Asset{
+				Source: variant.Source,
+				Generate: func() ([]byte, error) {
+					return site.ResizeImage(variant)
+				},
+				Path: variant.Path,
+			}
+		}
+	}
+
+	return nil
+}
+
+// Normalise the name of an asset, which is its path inside the assets
+// directory.
+func assetName(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+strings.TrimSpace(name)), "/")
+
+	return strings.TrimPrefix(name, "assets/")
+}
+
+// Get the path of an asset based on its name, e.g. "style.css".
+func lookupAsset(assets map[string]Asset, name string) (string, error) {
+	name = assetName(name)
+
+	asset, ok := assets[name]
+	if !ok {
+		return "", fmt.Errorf("Unknown asset: %v", name)
+	}
+
+	return asset.Path, nil
+}
+
+// Get the path of an asset based on its name, which is fingerprinted when the
+// assets are, falling back to the path in the assets directory for anything
+// which isn't an asset.
+func (site Site) AssetPath(name string) string {
+	if assetPath, err := lookupAsset(site.Assets, name); err == nil {
+		return assetPath
+	}
+
+	return path.Join("/assets", assetName(name))
+}
+
+// Make the manifest of the assets, mapping their names to their paths.
+func (site Site) AssetManifest() map[string]string {
+	manifest := map[string]string{}
+	for name, asset := range site.Assets {
+		manifest[name] = asset.Path
+	}
+
+	return manifest
+}
+
+// Find the asset which is available at the given path, which is either its
+// path or its path in the assets directory (as fingerprinted assets are
+// available without their fingerprint as well).
+func (site Site) FindAsset(assetPath string) (Asset, bool) {
+	for name, asset := range site.Assets {
+		if asset.Path == assetPath || path.Join("/assets", name) == assetPath {
+			return asset, true
+		}
+	}
+
+	return Asset{}, false
+}
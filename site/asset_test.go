@@ -0,0 +1,195 @@
+package site
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/michaelenger/brage/files"
+)
+
+func createAssetSite(t *testing.T) string {
+	return writeTestSite(t, map[string]string{
+		"assets/style.css":              "body {}",
+		"assets/images/cat.png":         "cat",
+		"themes/plain/assets/a.js":      "console.log('a')",
+		"themes/plain/assets/style.css": "p {}",
+	})
+}
+
+func TestLoadAssets(t *testing.T) {
+	dirPath := createAssetSite(t)
+
+	directories := []string{path.Join(dirPath, "themes/plain"), dirPath}
+
+	assets, err := loadAssets(directories, nil, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := map[string]Asset{
+		"a.js":           {Source: path.Join(dirPath, "themes/plain/assets/a.js"), Path: "/assets/a.js"},
+		"images/cat.png": {Source: path.Join(dirPath, "assets/images/cat.png"), Path: "/assets/images/cat.png"},
+		"style.css":      {Source: path.Join(dirPath, "assets/style.css"), Path: "/assets/style.css"},
+	}
+	if !reflect.DeepEqual(assets, expected) {
+		t.Fatalf("Received:\n%+v\nExpected:\n%+v", assets, expected)
+	}
+
+	assets, err = loadAssets(directories, nil, true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if assets["style.css"].Path != "/assets/style.62368a1a.css" {
+		t.Fatalf("Incorrect fingerprinted path: %v", assets["style.css"].Path)
+	}
+	if !strings.HasPrefix(assets["images/cat.png"].Path, "/assets/images/cat.") {
+		t.Fatalf("Incorrect fingerprinted path: %v", assets["images/cat.png"].Path)
+	}
+
+	site := Site{Assets: assets}
+	if asset, ok := site.FindAsset(assets["a.js"].Path); !ok || asset.Source != path.Join(dirPath, "themes/plain/assets/a.js") {
+		t.Fatalf("Unable to find asset: %+v", asset)
+	}
+	if asset, ok := site.FindAsset("/assets/a.js"); !ok || asset.Source != path.Join(dirPath, "themes/plain/assets/a.js") {
+		t.Fatalf("Unable to find asset without its fingerprint: %+v", asset)
+	}
+	if _, ok := site.FindAsset("/assets/nope.js"); ok {
+		t.Fatalf("Expected a missing asset to not be found")
+	}
+
+	if result := site.AssetPath("style.css"); result != "/assets/style.62368a1a.css" {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, "/assets/style.62368a1a.css")
+	}
+	if result := site.AssetPath("nope.png"); result != "/assets/nope.png" {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, "/assets/nope.png")
+	}
+}
+
+func TestAssetHelper(t *testing.T) {
+	assets := map[string]Asset{
+		"style.css": {Path: "/assets/style.62368a1a.css"},
+	}
+
+	for _, name := range []string{"style.css", " /style.css ", "/assets/style.css"} {
+		result, err := lookupAsset(assets, name)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if result != "/assets/style.62368a1a.css" {
+			t.Fatalf("Result:\n%v\nExpected:\n%v", result, "/assets/style.62368a1a.css")
+		}
+	}
+
+	expected := `<link href="/assets/style.62368a1a.css">`
+
+	result, err := mustacheEngine{assets: assets}.Render(`<link href="{{#asset}}{{ name }}{{/asset}}">`, "test", nil, map[string]interface{}{"name": "style.css"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+
+	result, err = goEngine{assets: assets}.Render(`<link href="{{ asset "style.css" }}">`, "test", nil, map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+
+	// Missing assets fail the build
+	_, err = mustacheEngine{assets: assets}.Render(`{{#asset}}missing.css{{/asset}}`, "test", nil, map[string]interface{}{})
+	if err == nil || !strings.Contains(err.Error(), "Unknown asset: missing.css") {
+		t.Fatalf("Expected error for missing asset but got: %v", err)
+	}
+	_, err = goEngine{assets: assets}.Render(`{{ asset "missing.css" }}`, "test", nil, map[string]interface{}{})
+	if err == nil || !strings.Contains(err.Error(), "Unknown asset: missing.css") {
+		t.Fatalf("Expected error for missing asset but got: %v", err)
+	}
+}
+
+func TestGeneratedAssets(t *testing.T) {
+	dirPath := createAssetSite(t)
+
+	config := "title: Assets\nfingerprint_assets: true\nmarkdown:\n  highlight:\n    style: monokai\n    classes: true\n"
+	if err := os.WriteFile(path.Join(dirPath, "config.yaml"), []byte(config), 0644); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if err := os.MkdirAll(path.Join(dirPath, "pages"), 0755); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	site, err := Load(dirPath, Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	stylesheet, err := files.HighlightStylesheet(site.Config.Markdown.Highlight)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expectedPath := files.FingerprintPath("/assets/highlight.css", []byte(stylesheet))
+	if result := site.AssetManifest()["highlight.css"]; result != expectedPath {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expectedPath)
+	}
+	if result, _ := lookupAsset(site.Assets, "highlight.css"); result != expectedPath {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expectedPath)
+	}
+
+	asset, ok := site.FindAsset(expectedPath)
+	if !ok || asset.IsFile() {
+		t.Fatalf("Incorrect asset: %+v", asset)
+	}
+	contents, err := asset.Contents()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(contents) != stylesheet {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", string(contents), stylesheet)
+	}
+}
+
+func TestLoadAssetBundles(t *testing.T) {
+	dirPath := createAssetSite(t)
+
+	directories := []string{path.Join(dirPath, "themes/plain"), dirPath}
+	bundles := map[string][]string{
+		"css/main.css": {"style.css", "a.js"},
+	}
+
+	assets, err := loadAssets(directories, bundles, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := Asset{
+		Bundle: []string{path.Join(dirPath, "assets/style.css"), path.Join(dirPath, "themes/plain/assets/a.js")},
+		Path:   "/assets/css/main.css",
+	}
+	if !reflect.DeepEqual(assets["css/main.css"], expected) {
+		t.Fatalf("Received:\n%+v\nExpected:\n%+v", assets["css/main.css"], expected)
+	}
+
+	contents, err := assets["css/main.css"].Contents()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(contents) != "body {}\nconsole.log('a')\n" {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", string(contents), "body {}\nconsole.log('a')\n")
+	}
+
+	assets, err = loadAssets(directories, bundles, true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(assets["css/main.css"].Path, "/assets/css/main.") || assets["css/main.css"].Path == "/assets/css/main.css" {
+		t.Fatalf("Incorrect fingerprinted path: %v", assets["css/main.css"].Path)
+	}
+
+	_, err = loadAssets(directories, map[string][]string{"main.css": {"missing.css"}}, false)
+	if err == nil || err.Error() != "Unknown asset in bundle main.css: missing.css" {
+		t.Fatalf("Expected error for missing asset but got: %v", err)
+	}
+}
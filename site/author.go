@@ -0,0 +1,192 @@
+package site
+
+import (
+	"log"
+	"os"
+	"path"
+	"sort"
+)
+
+// Templates used for author pages when the site doesn't define one, for
+// each of the template engines.
+var defaultAuthorTemplates = map[string]string{
+	MustacheEngine: `<h1>{{ item.name }}</h1>
+{{ #item.avatar }}<img src="{{ item.avatar }}" alt="{{ item.name }}">{{ /item.avatar }}
+{{ #item.bio }}<p>{{ item.bio }}</p>{{ /item.bio }}
+<ul>
+{{ #item.posts }}
+<li>{{ date }} <a href="{{ path }}">{{ title }}</a></li>
+{{ /item.posts }}
+</ul>
+`,
+	GoEngine: `<h1>{{ .item.name }}</h1>
+{{ with .item.avatar }}<img src="{{ . }}" alt="{{ $.item.name }}">{{ end }}
+{{ with .item.bio }}<p>{{ . }}</p>{{ end }}
+<ul>
+{{ range .item.posts }}
+<li>{{ .date }} <a href="{{ .path }}">{{ .title }}</a></li>
+{{ end }}
+</ul>
+`,
+}
+
+// A link on an author's profile.
+type AuthorLink struct {
+	Name string
+	Url  string
+}
+
+// Profile of an author.
+type AuthorConfig struct {
+	Name   string
+	Bio    string
+	Avatar string
+	Links  []AuthorLink
+}
+
+// Get the IDs of the authors from the metadata of a post.
+func authorIds(metadata map[string]interface{}) []string {
+	var ids []string
+
+	for _, key := range []string{"author", "authors"} {
+		switch val := metadata[key].(type) {
+		case string:
+			ids = append(ids, val)
+		case []interface{}:
+			for _, id := range val {
+				if id, ok := id.(string); ok {
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
+
+	return ids
+}
+
+// Log a warning for each author of a post which isn't defined in the config,
+// returning their IDs.
+func (site Site) checkAuthors() []string {
+	unknown := []string{}
+	seen := map[string]bool{}
+
+	for _, name := range sortedCollectionNames(site) {
+		for _, post := range site.Collections[name].Entries {
+			for _, id := range post.Authors {
+				if _, ok := site.Config.Authors[id]; ok {
+					continue
+				}
+
+				logger := log.Default()
+				logger.Printf("Unknown author in %v: %v", post.Source, id)
+				if !seen[id] {
+					seen[id] = true
+					unknown = append(unknown, id)
+				}
+			}
+		}
+	}
+	sort.Strings(unknown)
+
+	return unknown
+}
+
+// Get the profile of an author, falling back to using the ID as the name.
+func (site Site) authorProfile(id string) AuthorConfig {
+	profile, ok := site.Config.Authors[id]
+	if !ok {
+		return AuthorConfig{Name: id}
+	}
+
+	if profile.Name == "" {
+		profile.Name = id
+	}
+
+	return profile
+}
+
+// Get the names of the authors of a post, falling back to the site author.
+func (site Site) AuthorNames(post Post) []string {
+	if len(post.Authors) == 0 {
+		return []string{site.Config.Author}
+	}
+
+	names := make([]string, len(post.Authors))
+	for i, id := range post.Authors {
+		names[i] = site.authorProfile(id).Name
+	}
+
+	return names
+}
+
+// Make the context used for an author in the templates.
+func (site Site) makeAuthorContext(id string) map[string]interface{} {
+	profile := site.authorProfile(id)
+
+	links := make([]map[string]string, len(profile.Links))
+	for i, link := range profile.Links {
+		links[i] = map[string]string{
+			"name": link.Name,
+			"url":  link.Url,
+		}
+	}
+
+	context := map[string]interface{}{
+		"id":     id,
+		"name":   profile.Name,
+		"bio":    profile.Bio,
+		"avatar": profile.Avatar,
+		"links":  links,
+	}
+	if _, ok := site.Config.Authors[id]; ok {
+		context["path"] = path.Join("/authors", id)
+	}
+
+	return context
+}
+
+// Make the pages for each of the authors defined in the config.
+func makeAuthorPages(site Site) ([]Page, error) {
+	pages := []Page{}
+	if len(site.Config.Authors) == 0 {
+		return pages, nil
+	}
+
+	template := defaultAuthorTemplates[site.engineName()]
+	source := "author template"
+	if templatePath, ok := site.ResolvePath("author.html"); ok {
+		source = templatePath
+		contents, err := os.ReadFile(templatePath)
+		if err != nil {
+			return pages, err
+		}
+		template = string(contents)
+	}
+
+	for id := range site.Config.Authors {
+		posts := []Post{}
+		for _, collection := range site.Collections {
+			for _, post := range collection.Entries {
+				for _, authorId := range post.Authors {
+					if authorId == id {
+						posts = append(posts, post)
+						break
+					}
+				}
+			}
+		}
+		sortEntries(posts, "date", "desc")
+
+		author := site.makeAuthorContext(id)
+		author["posts"] = Collection{Entries: posts}.makeContext()
+
+		pages = append(pages, Page{
+			Path:     path.Join("/authors", id),
+			Source:   source,
+			Template: template,
+			Item:     author,
+		})
+	}
+
+	return pages, nil
+}
@@ -0,0 +1,166 @@
+package site
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testAuthors = map[string]AuthorConfig{
+	"alice": {
+		Name:   "Alice Allison",
+		Bio:    "Plays the drums.",
+		Avatar: "/assets/alice.png",
+		Links: []AuthorLink{
+			{Name: "Website", Url: "https://example.org/alice"},
+		},
+	},
+	"bob": {},
+}
+
+func TestAuthorIds(t *testing.T) {
+	var tests = []struct {
+		metadata map[string]interface{}
+		expected []string
+	}{
+		{map[string]interface{}{}, nil},
+		{map[string]interface{}{"author": "alice"}, []string{"alice"}},
+		{map[string]interface{}{"authors": []interface{}{"alice", "bob"}}, []string{"alice", "bob"}},
+		{map[string]interface{}{"author": "alice", "authors": []interface{}{"bob"}}, []string{"alice", "bob"}},
+	}
+
+	for _, test := range tests {
+		result := authorIds(test.metadata)
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Fatalf("Received:\n%+v\nExpected:\n%+v", result, test.expected)
+		}
+	}
+}
+
+func TestAuthorNames(t *testing.T) {
+	site := Site{Config: SiteConfig{Author: "Site Author", Authors: testAuthors}}
+
+	var tests = []struct {
+		authors  []string
+		expected []string
+	}{
+		{nil, []string{"Site Author"}},
+		{[]string{"alice"}, []string{"Alice Allison"}},
+		{[]string{"bob", "carol"}, []string{"bob", "carol"}},
+	}
+
+	for _, test := range tests {
+		result := site.AuthorNames(Post{Authors: test.authors})
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Fatalf("Received:\n%+v\nExpected:\n%+v", result, test.expected)
+		}
+	}
+}
+
+func TestMakeAuthorContext(t *testing.T) {
+	site := Site{Config: SiteConfig{Authors: testAuthors}}
+
+	expected := map[string]interface{}{
+		"id":     "alice",
+		"name":   "Alice Allison",
+		"bio":    "Plays the drums.",
+		"avatar": "/assets/alice.png",
+		"links": []map[string]string{
+			{"name": "Website", "url": "https://example.org/alice"},
+		},
+		"path": "/authors/alice",
+	}
+
+	result := site.makeAuthorContext("alice")
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("Received:\n%+v\nExpected:\n%+v", result, expected)
+	}
+}
+
+func TestCheckAuthors(t *testing.T) {
+	site := Site{
+		Config: SiteConfig{Authors: testAuthors},
+		Collections: map[string]Collection{
+			"posts": {Entries: []Post{
+				{Source: "/posts/first.md", Authors: []string{"alice", "carol"}},
+				{Source: "/posts/second.md", Authors: []string{"carol", "bob"}},
+			}},
+			"notes": {Entries: []Post{
+				{Source: "/notes/first.md", Authors: []string{"dave"}},
+			}},
+		},
+	}
+
+	result := site.checkAuthors()
+	expected := []string{"carol", "dave"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("Received:\n%+v\nExpected:\n%+v", result, expected)
+	}
+}
+
+func TestLoadUnknownAuthorsStrict(t *testing.T) {
+	dirPath := writeTestSite(t, map[string]string{
+		"config.yaml":         "title: Authors\nauthors:\n  alice:\n    name: Alice\n",
+		"pages/index.html":    "Hello",
+		"posts/first-post.md": "---\ntitle: First\nauthors:\n  - alice\n  - carol\n---\nHello",
+	})
+
+	if _, err := Load(dirPath, Options{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	_, err := Load(dirPath, Options{Strict: true})
+	if err == nil || !strings.Contains(err.Error(), "carol") {
+		t.Fatalf("Expected error for unknown author but got: %v", err)
+	}
+}
+
+func TestMakeAuthorPages(t *testing.T) {
+	date, _ := time.Parse(time.DateOnly, "2010-09-08")
+	site := Site{
+		Config:          SiteConfig{Authors: testAuthors},
+		SourceDirectory: "/this/does/not/exist",
+		Collections: map[string]Collection{
+			"posts": {
+				Entries: []Post{
+					{Path: "/first", Title: "First", Date: date, Authors: []string{"alice"}},
+					{Path: "/second", Title: "Second", Date: date, Authors: []string{"bob"}},
+				},
+			},
+		},
+	}
+
+	pages, err := makeAuthorPages(site)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(pages) != 2 {
+		t.Fatalf("Incorrect pages: %+v", pages)
+	}
+
+	for _, page := range pages {
+		if page.Path != "/authors/alice" {
+			continue
+		}
+
+		result, err := page.Render(Site{Layouts: map[string]Layout{PageLayout: {Template: "{{{ content }}}"}}})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		expected := `<h1>Alice Allison</h1>
+<img src="/assets/alice.png" alt="Alice Allison">
+<p>Plays the drums.</p>
+<ul>
+<li>2010-09-08 <a href="/first">First</a></li>
+</ul>
+`
+		if result != expected {
+			t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+		}
+		return
+	}
+
+	t.Fatalf("No page found for alice: %+v", pages)
+}
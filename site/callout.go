@@ -0,0 +1,37 @@
+package site
+
+import (
+	"html/template"
+
+	"github.com/michaelenger/brage/files"
+)
+
+// The name of the partial used to render callouts.
+const CalloutPartial = "callout"
+
+// Render a callout using the callout partial, or the default markup if the
+// site doesn't have one.
+func (site Site) renderCallout(callout files.Callout) (string, error) {
+	calloutTemplate, ok := site.Partials[CalloutPartial]
+	if !ok {
+		return files.DefaultCallout(callout), nil
+	}
+
+	context := map[string]interface{}{
+		"kind":    callout.Kind,
+		"title":   callout.Title,
+		"content": template.HTML(callout.Content),
+		"data":    site.Config.Data,
+	}
+
+	source := partialSource(site.PartialPaths, CalloutPartial)
+	rendered, err := site.engine().Render(calloutTemplate, source, nil, context)
+	if err != nil {
+		return "", err
+	}
+	if markdownPartial(site.PartialPaths, CalloutPartial) {
+		rendered = site.Markdown.Render([]byte(dedentMarkdown(rendered)))
+	}
+
+	return rendered, nil
+}
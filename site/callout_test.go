@@ -0,0 +1,85 @@
+package site
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/michaelenger/brage/files"
+)
+
+func TestRenderCallout(t *testing.T) {
+	callout := files.Callout{Kind: "note", Title: "Heads up", Content: "<p>Hello</p>"}
+
+	// Without a partial the default markup is used
+	site := Site{Markdown: newTestMarkdown(t, files.MarkdownConfig{})}
+	result, err := site.renderCallout(callout)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result != files.DefaultCallout(callout) {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, files.DefaultCallout(callout))
+	}
+
+	site.Partials = map[string]string{
+		"callout": `<aside class="{{ kind }}"><h4>{{ title }}</h4>{{{ content }}}</aside>`,
+	}
+	result, err = site.renderCallout(callout)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := `<aside class="note"><h4>Heads up</h4><p>Hello</p></aside>`
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+
+	site.Config.TemplateEngine = GoEngine
+	site.Partials["callout"] = `<aside class="{{ .kind }}">{{ .content }}</aside>`
+	result, err = site.renderCallout(callout)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected = `<aside class="note"><p>Hello</p></aside>`
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+}
+
+func TestLoadWithCalloutPartial(t *testing.T) {
+	test_files := map[string]string{
+		"partials/callout.html": `<aside class="custom-{{ kind }}">{{{ content }}}</aside>`,
+		"partials/tips.md":      "> [!TIP]\n> Partial",
+		"pages/index.md":        "> [!NOTE]\n> Page\n",
+		"posts/post.md":         "---\ntitle: Post\n---\n:::warning\nPost\n:::\n",
+		"config.yaml":           "title: Callouts\n",
+	}
+	temporaryDirectory := writeTestSite(t, test_files)
+
+	site, err := Load(temporaryDirectory, Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(site.Pages) != 1 || !strings.Contains(site.Pages[0].Template, `<aside class="custom-note"><p>Page</p>`) {
+		t.Fatalf("Incorrect site.Pages: %+v", site.Pages)
+	}
+	if len(site.Posts) != 1 || !strings.Contains(site.Posts[0].Template, `<aside class="custom-warning"><p>Post</p>`) {
+		t.Fatalf("Incorrect site.Posts: %+v", site.Posts)
+	}
+
+	// Loading another site doesn't change how this one renders markdown
+	otherDirectory := writeTestSite(t, map[string]string{
+		"pages/index.md": "Other",
+		"config.yaml":    "title: Other\n",
+	})
+	if _, err := Load(otherDirectory, Options{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	result, err := site.engine().Render("{{> tips }}", "test", nil, map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(result, `<aside class="custom-tip"><p>Partial</p>`) {
+		t.Fatalf("Result:\n%v\nExpected the callout in the partial to be rendered", result)
+	}
+}
@@ -0,0 +1,216 @@
+package site
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/michaelenger/brage/files"
+)
+
+// Name of the built-in collection of blog posts.
+const PostsCollection = "posts"
+
+// Configuration for a collection of content.
+type CollectionConfig struct {
+	Directory string
+	Layout    string
+	SortBy    string `yaml:"sort_by"`
+	SortOrder string `yaml:"sort_order"`
+	Permalink string
+	Feed      string
+}
+
+// A collection of content, such as blog posts or projects.
+type Collection struct {
+	Name    string
+	Config  CollectionConfig
+	Layout  string
+	Entries []Post
+}
+
+// Get the collection config, filling in defaults for anything not specified.
+func collectionConfigWithDefaults(name string, config CollectionConfig) CollectionConfig {
+	if config.Directory == "" {
+		config.Directory = name
+	}
+	if config.SortBy == "" {
+		config.SortBy = "date"
+	}
+	if config.SortOrder == "" {
+		if config.SortBy == "date" {
+			config.SortOrder = "desc"
+		} else {
+			config.SortOrder = "asc"
+		}
+	}
+	if config.Permalink == "" {
+		if name == PostsCollection {
+			config.Permalink = "/:path"
+		} else {
+			config.Permalink = "/:collection/:path"
+		}
+	}
+	if config.Feed == "" && name == PostsCollection {
+		config.Feed = "feed.rss"
+	}
+
+	return config
+}
+
+// Convert a string into something which can be used in a URL.
+func slugify(text string) string {
+	var builder strings.Builder
+	dash := false
+
+	for _, r := range strings.ToLower(text) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			builder.WriteRune(r)
+			dash = false
+		} else if !dash && builder.Len() > 0 {
+			builder.WriteRune('-')
+			dash = true
+		}
+	}
+
+	return strings.TrimSuffix(builder.String(), "-")
+}
+
+// Build the path of a collection entry based on a permalink pattern.
+func makePermalink(pattern string, collection string, name string, post Post) string {
+	slug := path.Base(name)
+	if val, ok := post.Metadata["slug"].(string); ok && val != "" {
+		slug = val
+	}
+
+	replacer := strings.NewReplacer(
+		":collection", collection,
+		":path", name,
+		":slug", slug,
+		":title", slugify(post.Title),
+		":year", post.Date.Format("2006"),
+		":month", post.Date.Format("01"),
+		":day", post.Date.Format("02"),
+	)
+
+	return path.Clean("/" + replacer.Replace(pattern))
+}
+
+// Get the value used to sort an entry by the given key.
+func sortValue(post Post, key string) string {
+	switch key {
+	case "date":
+		return post.Date.Format("2006-01-02 15:04:05")
+	case "title":
+		return post.Title
+	case "path":
+		return post.Path
+	}
+
+	if val, ok := post.Metadata[key]; ok {
+		return fmt.Sprint(val)
+	}
+
+	return ""
+}
+
+// Sort the entries of a collection based on its config.
+func sortEntries(entries []Post, sortBy string, sortOrder string) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := sortValue(entries[i], sortBy), sortValue(entries[j], sortBy)
+		if sortOrder == "desc" {
+			a, b = b, a
+		}
+
+		// Compare numbers by value rather than alphabetically
+		numberA, errA := strconv.ParseFloat(a, 64)
+		numberB, errB := strconv.ParseFloat(b, 64)
+		if errA == nil && errB == nil {
+			return numberA < numberB
+		}
+
+		return a < b
+	})
+}
+
+// Load a collection from the site directory.
+func loadCollection(site Site, name string, config CollectionConfig) (Collection, error) {
+	config = collectionConfigWithDefaults(name, config)
+	collection := Collection{
+		Name:    name,
+		Config:  config,
+		Layout:  PostLayout,
+		Entries: []Post{},
+	}
+
+	// Entries use the layout named after the collection if there is one
+	if config.Layout != "" {
+		if _, ok := site.Layouts[config.Layout]; !ok {
+			return collection, fmt.Errorf("Unknown layout for collection %v: %v", name, config.Layout)
+		}
+		collection.Layout = config.Layout
+	} else if _, ok := site.Layouts[name]; ok && name != PostsCollection {
+		collection.Layout = name
+	}
+
+	dirPath := path.Join(site.SourceDirectory, config.Directory)
+	dirFileInfo, err := os.Stat(dirPath)
+	if err != nil || !dirFileInfo.IsDir() {
+		return collection, nil
+	}
+
+	entryFiles, err := files.ReadFiles(dirPath, "")
+	if err != nil {
+		return collection, err
+	}
+	for fileName, file := range entryFiles {
+		// An entry can be a directory with a markdown index file, along with
+		// any other files it uses
+		bundle := file.Type == files.MarkdownFile && path.Base(fileName) == "index" && path.Dir(fileName) != "."
+		var resources map[string]string
+		if bundle {
+			fileName = path.Dir(fileName)
+			resources, err = files.ReadResources(path.Dir(file.Path))
+			if err != nil {
+				return collection, err
+			}
+		}
+
+		file, restoreShortcodes, err := site.prepareContent(file)
+		if err != nil {
+			return collection, err
+		}
+
+		post, content := readPost(file, "")
+		if _, ok := post.Metadata["title"]; bundle && !ok {
+			post.Title = files.PathToTitle(fileName)
+		}
+		post.Collection = name
+		post.Path = makePermalink(config.Permalink, name, fileName, post)
+		post.renderContent(site.Markdown, file.Type, content, resourceLinks(post.Path, resources))
+		post.Template = restoreShortcodes(post.Template)
+		post.Resources = makeResources(post.Path, resources)
+		collection.Entries = append(collection.Entries, post)
+	}
+
+	sortEntries(collection.Entries, config.SortBy, config.SortOrder)
+
+	return collection, nil
+}
+
+// Make the list of entries used in the site context.
+func (collection Collection) makeContext() []map[string]string {
+	entries := make([]map[string]string, len(collection.Entries))
+	for i := range collection.Entries {
+		entries[i] = map[string]string{
+			"path":  collection.Entries[i].Path,
+			"title": collection.Entries[i].Title,
+			"date":  collection.Entries[i].Date.Format("2006-01-02"),
+		}
+	}
+
+	return entries
+}
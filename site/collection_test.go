@@ -0,0 +1,216 @@
+package site
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/michaelenger/brage/files"
+)
+
+func TestMakePermalink(t *testing.T) {
+	date, _ := time.Parse(time.DateOnly, "2021-03-04")
+	post := Post{
+		Title: "Hello, World!",
+		Date:  date,
+	}
+
+	var tests map[string]string = map[string]string{
+		"/:path":                     "/sub/some-post",
+		"/:collection/:path":         "/projects/sub/some-post",
+		"/:year/:month/:day/:slug":   "/2021/03/04/some-post",
+		"/writing/:title/":           "/writing/hello-world",
+		":collection/archive/:slug/": "/projects/archive/some-post",
+	}
+
+	for pattern, expected := range tests {
+		result := makePermalink(pattern, "projects", "sub/some-post", post)
+		if result != expected {
+			t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+		}
+	}
+
+	post.Metadata = map[string]interface{}{"slug": "custom"}
+	result := makePermalink("/:slug", "projects", "sub/some-post", post)
+	if result != "/custom" {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, "/custom")
+	}
+}
+
+func TestSortEntries(t *testing.T) {
+	first, _ := time.Parse(time.DateOnly, "2020-01-01")
+	second, _ := time.Parse(time.DateOnly, "2021-01-01")
+	entries := []Post{
+		{Path: "/b", Title: "Banana", Date: first, Metadata: map[string]interface{}{"weight": 2}},
+		{Path: "/a", Title: "Cherry", Date: second, Metadata: map[string]interface{}{"weight": 1}},
+		{Path: "/c", Title: "Apple", Date: first, Metadata: map[string]interface{}{"weight": 10}},
+	}
+
+	var tests = []struct {
+		sortBy    string
+		sortOrder string
+		expected  []string
+	}{
+		{"date", "desc", []string{"/a", "/b", "/c"}},
+		{"title", "asc", []string{"/c", "/b", "/a"}},
+		{"path", "desc", []string{"/c", "/b", "/a"}},
+		{"weight", "asc", []string{"/a", "/b", "/c"}},
+	}
+
+	for _, test := range tests {
+		sortEntries(entries, test.sortBy, test.sortOrder)
+
+		result := make([]string, len(entries))
+		for i := range entries {
+			result[i] = entries[i].Path
+		}
+
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Fatalf("Sorting by %v %v\nReceived:\n%+v\nExpected:\n%+v", test.sortBy, test.sortOrder, result, test.expected)
+		}
+	}
+}
+
+func TestLoadCollection(t *testing.T) {
+	dirPath := createExampleSite(t)
+	defer os.RemoveAll(dirPath)
+
+	projectsPath := path.Join(dirPath, "work")
+	if err := os.Mkdir(projectsPath, 0755); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := os.WriteFile(path.Join(projectsPath, "brage.markdown"), []byte("---\ntitle: Brage\norder: 2\n---\nA site generator."), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := os.WriteFile(path.Join(projectsPath, "other.markdown"), []byte("---\ntitle: Other\norder: 1\n---\nSomething else."), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := os.WriteFile(path.Join(dirPath, "layout-projects.html"), []byte("This is the projects layout"), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	config := CollectionConfig{
+		Directory: "work",
+		SortBy:    "order",
+		Permalink: "/projects/:slug",
+	}
+	layouts, err := loadLayouts([]string{dirPath}, "{{{ content }}}")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	site := Site{SourceDirectory: dirPath, Layouts: layouts, Markdown: newTestMarkdown(t, files.MarkdownConfig{})}
+	collection, err := loadCollection(site, "projects", config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if collection.Layout != "projects" {
+		t.Fatalf("Incorrect collection.Layout: %v", collection.Layout)
+	}
+	if len(collection.Entries) != 2 {
+		t.Fatalf("Incorrect collection.Entries: %v", collection.Entries)
+	}
+	if collection.Entries[0].Path != "/projects/other" {
+		t.Fatalf("Incorrect collection.Entries[0].Path: %v", collection.Entries[0].Path)
+	}
+	if collection.Entries[1].Path != "/projects/brage" {
+		t.Fatalf("Incorrect collection.Entries[1].Path: %v", collection.Entries[1].Path)
+	}
+	if collection.Entries[1].Collection != "projects" {
+		t.Fatalf("Incorrect collection.Entries[1].Collection: %v", collection.Entries[1].Collection)
+	}
+}
+
+func TestLoadCollectionMissingLayout(t *testing.T) {
+	dirPath := createExampleSite(t)
+	defer os.RemoveAll(dirPath)
+
+	site := Site{SourceDirectory: dirPath, Layouts: map[string]Layout{}, Markdown: newTestMarkdown(t, files.MarkdownConfig{})}
+	_, err := loadCollection(site, "projects", CollectionConfig{Layout: "nope"})
+	if err == nil {
+		t.Fatalf("Expected error but got nil")
+	}
+}
+
+func TestLoadCollectionBundles(t *testing.T) {
+	dirPath := createExampleSite(t)
+	defer os.RemoveAll(dirPath)
+
+	test_files := map[string]string{
+		"trips/trip-to-oslo/index.md":        "![Opera](opera.jpg)\n\n[Route](./maps/route.gpx?v=2) and [elsewhere](other.jpg) <img src=\"opera.jpg\">\n\n    <a href=\"opera.jpg\">\n",
+		"trips/trip-to-oslo/opera.jpg":       "opera",
+		"trips/trip-to-oslo/maps/route.gpx":  "route",
+		"trips/bergen/index.md":              "---\ntitle: Rainy Bergen\n---\n![Rain](rain.jpg)",
+		"trips/bergen/rain.jpg":              "rain",
+		"trips/index.md":                     "Not a bundle",
+		"trips/trip-to-oslo/extra-day.md":    "![Opera](opera.jpg)",
+		"trips/trip-to-oslo/maps/.gitignore": "",
+		"trips/stavanger/index.html":         "<img src=\"fjord.jpg\">",
+		"trips/stavanger/fjord.jpg":          "fjord",
+	}
+	for filename, contents := range test_files {
+		filePath := path.Join(dirPath, filename)
+		if err := os.MkdirAll(path.Dir(filePath), 0755); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if err := os.WriteFile(filePath, []byte(contents), 0644); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+
+	site := Site{SourceDirectory: dirPath, Markdown: newTestMarkdown(t, files.MarkdownConfig{})}
+	collection, err := loadCollection(site, "trips", CollectionConfig{SortBy: "path"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	posts := map[string]Post{}
+	for _, post := range collection.Entries {
+		posts[post.Path] = post
+	}
+	if len(posts) != 5 {
+		t.Fatalf("Incorrect collection.Entries: %+v", collection.Entries)
+	}
+
+	oslo := posts["/trips/trip-to-oslo"]
+	if oslo.Title != "Trip To Oslo" {
+		t.Fatalf("Incorrect title: %v", oslo.Title)
+	}
+	// Only the links written in markdown are rewritten
+	expectedTemplate := `<p><img src="/trips/trip-to-oslo/opera.jpg" alt="Opera"></p>
+<p><a href="/trips/trip-to-oslo/maps/route.gpx?v=2">Route</a> and <a href="other.jpg">elsewhere</a> <img src="opera.jpg"></p>
+<pre><code>&lt;a href=&quot;opera.jpg&quot;&gt;
+</code></pre>
+`
+	if oslo.Template != expectedTemplate {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", oslo.Template, expectedTemplate)
+	}
+	expectedResources := []Resource{
+		{Source: path.Join(dirPath, "trips/trip-to-oslo/maps/route.gpx"), Path: "/trips/trip-to-oslo/maps/route.gpx"},
+		{Source: path.Join(dirPath, "trips/trip-to-oslo/opera.jpg"), Path: "/trips/trip-to-oslo/opera.jpg"},
+	}
+	if !reflect.DeepEqual(oslo.Resources, expectedResources) {
+		t.Fatalf("Received:\n%+v\nExpected:\n%+v", oslo.Resources, expectedResources)
+	}
+
+	bergen := posts["/trips/bergen"]
+	if bergen.Title != "Rainy Bergen" || bergen.Template != "<p><img src=\"/trips/bergen/rain.jpg\" alt=\"Rain\"></p>\n" {
+		t.Fatalf("Incorrect post: %+v", bergen)
+	}
+
+	// Other entries don't have resources, including directories with an HTML
+	// index file
+	if len(posts["/trips/index"].Resources) != 0 || len(posts["/trips/trip-to-oslo/extra-day"].Resources) != 0 || len(posts["/trips/stavanger/index"].Resources) != 0 {
+		t.Fatalf("Expected no resources for entries which aren't bundles")
+	}
+
+	site.Collections = map[string]Collection{"trips": collection}
+	if resource, ok := site.FindResource("/trips/bergen/rain.jpg"); !ok || resource.Source != path.Join(dirPath, "trips/bergen/rain.jpg") {
+		t.Fatalf("Unable to find resource: %+v", resource)
+	}
+	if _, ok := site.FindResource("/trips/bergen/missing.jpg"); ok {
+		t.Fatalf("Expected the missing resource to not be found")
+	}
+}
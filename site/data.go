@@ -0,0 +1,175 @@
+package site
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/michaelenger/brage/files"
+	"gopkg.in/yaml.v2"
+)
+
+// File extensions of the supported data files.
+var dataFileExtensions = map[string]bool{
+	".yaml": true,
+	".yml":  true,
+	".json": true,
+	".toml": true,
+	".csv":  true,
+}
+
+// Read a CSV file with a header row into a list of records.
+func parseCsv(contents []byte) ([]map[string]string, error) {
+	reader := csv.NewReader(bytes.NewReader(contents))
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	records := []map[string]string{}
+	if len(rows) == 0 {
+		return records, nil
+	}
+
+	header := rows[0]
+	for _, row := range rows[1:] {
+		record := map[string]string{}
+		for i, value := range row {
+			if i < len(header) {
+				record[header[i]] = value
+			}
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// Load a data file, parsing it based on its file extension.
+func loadDataFile(filePath string) (interface{}, error) {
+	contents, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var data interface{}
+
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(contents, &data)
+	case ".json":
+		err = json.Unmarshal(contents, &data)
+	case ".toml":
+		var tomlData map[string]interface{}
+		err = toml.Unmarshal(contents, &tomlData)
+		data = tomlData
+	case ".csv":
+		data, err = parseCsv(contents)
+	default:
+		return nil, fmt.Errorf("Unsupported data file: %v", filePath)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse data file %v: %v", filePath, err)
+	}
+
+	return normaliseData(data), nil
+}
+
+// Convert the maps in decoded data (which differ depending on the format) to
+// data maps, so that they can be merged.
+func normaliseData(data interface{}) interface{} {
+	switch value := data.(type) {
+	case DataMap:
+		for key, item := range value {
+			value[key] = normaliseData(item)
+		}
+		return value
+	case map[interface{}]interface{}:
+		return normaliseData(DataMap(value))
+	case map[string]interface{}:
+		dataMap := make(DataMap, len(value))
+		for key, item := range value {
+			dataMap[key] = normaliseData(item)
+		}
+		return dataMap
+	case []interface{}:
+		for i, item := range value {
+			value[i] = normaliseData(item)
+		}
+		return value
+	case []map[string]interface{}:
+		items := make([]interface{}, len(value))
+		for i, item := range value {
+			items[i] = normaliseData(item)
+		}
+		return items
+	}
+
+	return data
+}
+
+// Merge the source data into the target, combining any maps present in both.
+func mergeData(target DataMap, source DataMap) {
+	for key, value := range source {
+		targetMap, targetIsMap := target[key].(DataMap)
+		sourceMap, sourceIsMap := value.(DataMap)
+		if targetIsMap && sourceIsMap {
+			mergeData(targetMap, sourceMap)
+		} else {
+			target[key] = value
+		}
+	}
+}
+
+// Load the data files in a directory, keyed by their path.
+func loadDataDirectory(dirPath string) (DataMap, error) {
+	data := DataMap{}
+
+	dirFileInfo, err := os.Stat(dirPath)
+	if err != nil || !dirFileInfo.IsDir() {
+		return data, nil
+	}
+
+	entries, err := os.ReadDir(dirPath)
+	if err != nil {
+		return data, err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if name[0] == '.' {
+			continue
+		}
+
+		fullPath := path.Join(dirPath, name)
+
+		if entry.IsDir() {
+			subdata, err := loadDataDirectory(fullPath)
+			if err != nil {
+				return data, err
+			}
+			mergeData(data, DataMap{name: subdata})
+
+			continue
+		}
+
+		if !dataFileExtensions[strings.ToLower(filepath.Ext(name))] {
+			continue
+		}
+
+		fileData, err := loadDataFile(fullPath)
+		if err != nil {
+			return data, err
+		}
+		mergeData(data, DataMap{files.FileName(name): fileData})
+	}
+
+	return data, nil
+}
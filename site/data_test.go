@@ -0,0 +1,139 @@
+package site
+
+import (
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestLoadDataDirectory(t *testing.T) {
+	test_files := map[string]string{
+		"config.yaml":        "name: Example\ntags:\n  - one\n  - two\n",
+		"numbers.json":       `{"one": 1, "two": [2, 2]}`,
+		"settings.toml":      "enabled = true\ntitle = \"Settings\"\n",
+		"team/members.csv":   "name,role\nAlice,Drums\nBob,Bass\n",
+		"team/leader.yml":    "Alice",
+		"team/README.md":     "This is ignored",
+		".hidden/secret.yml": "hidden: true",
+	}
+
+	temporaryDirectory := writeTestSite(t, test_files)
+
+	result, err := loadDataDirectory(temporaryDirectory)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := DataMap{
+		"config": DataMap{
+			"name": "Example",
+			"tags": []interface{}{"one", "two"},
+		},
+		"numbers": DataMap{
+			"one": float64(1),
+			"two": []interface{}{float64(2), float64(2)},
+		},
+		"settings": DataMap{
+			"enabled": true,
+			"title":   "Settings",
+		},
+		"team": DataMap{
+			"members": []map[string]string{
+				{"name": "Alice", "role": "Drums"},
+				{"name": "Bob", "role": "Bass"},
+			},
+			"leader": "Alice",
+		},
+	}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("Received:\n%+v\nExpected:\n%+v", result, expected)
+	}
+}
+
+func TestLoadDataMergedWithConfig(t *testing.T) {
+	test_files := map[string]string{
+		"config.yaml":        "title: Merged\ndata:\n  numbers:\n    one: 1\n  settings:\n    theme: dark\n  people:\n    alice:\n      role: Drums\n",
+		"data/numbers.json":  `{"two": {"value": 2}}`,
+		"data/settings.toml": "enabled = true\n\n[colours]\nbackground = \"black\"\n",
+		"data/people.yaml":   "alice:\n  name: Alice\nbob:\n  name: Bob\n",
+		"pages/index.html":   "Hello",
+	}
+
+	temporaryDirectory := writeTestSite(t, test_files)
+
+	site, err := Load(temporaryDirectory, Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := DataMap{
+		"numbers": DataMap{
+			"one": 1,
+			"two": DataMap{"value": float64(2)},
+		},
+		"settings": DataMap{
+			"theme":   "dark",
+			"enabled": true,
+			"colours": DataMap{"background": "black"},
+		},
+		"people": DataMap{
+			"alice": DataMap{"name": "Alice", "role": "Drums"},
+			"bob":   DataMap{"name": "Bob"},
+		},
+	}
+	if !reflect.DeepEqual(site.Config.Data, expected) {
+		t.Fatalf("Received:\n%+v\nExpected:\n%+v", site.Config.Data, expected)
+	}
+}
+
+func TestLoadDataDirectoryMissing(t *testing.T) {
+	result, err := loadDataDirectory("/this/does/not/exist")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(result) != 0 {
+		t.Fatalf("Expected empty data but got: %+v", result)
+	}
+}
+
+func TestLoadDataFileInvalid(t *testing.T) {
+	temporaryDirectory := writeTestSite(t, map[string]string{"broken.json": "{nope"})
+
+	_, err := loadDataFile(path.Join(temporaryDirectory, "broken.json"))
+	if err == nil {
+		t.Fatalf("Expected error but got nil")
+	}
+}
+
+func TestMergeData(t *testing.T) {
+	target := DataMap{
+		"one": 1,
+		"sub": DataMap{
+			"two":   2,
+			"three": 3,
+		},
+	}
+	source := DataMap{
+		"four": 4,
+		"sub": DataMap{
+			"three": "three",
+		},
+	}
+
+	mergeData(target, source)
+
+	expected := DataMap{
+		"one":  1,
+		"four": 4,
+		"sub": DataMap{
+			"two":   2,
+			"three": "three",
+		},
+	}
+
+	if !reflect.DeepEqual(target, expected) {
+		t.Fatalf("Received:\n%+v\nExpected:\n%+v", target, expected)
+	}
+}
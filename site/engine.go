@@ -0,0 +1,640 @@
+package site
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/cbroglie/mustache"
+	"github.com/michaelenger/brage/files"
+)
+
+// Names of the supported template engines.
+const (
+	MustacheEngine = "mustache"
+	GoEngine       = "go"
+)
+
+// Templates which only output the content, for each of the template engines.
+var contentTemplates = map[string]string{
+	MustacheEngine: "{{{ content }}}",
+	GoEngine:       "{{ .content }}",
+}
+
+// A template engine used to render pages, posts, layouts and partials.
+type Engine interface {
+	// Render a template from the given source file, wrapping it in the given
+	// layouts (innermost first).
+	Render(template string, source string, layouts []Layout, context map[string]interface{}) (string, error)
+}
+
+// An error which occurred when rendering a template, along with the file the
+// template came from and the position in it (if known).
+type TemplateError struct {
+	File   string
+	Line   int
+	Column int
+	Err    error
+}
+
+// Make a template error, adjusting the line to take into account any front
+// matter in the file as that isn't part of the template. Markdown is rendered
+// to HTML before the template is, so the position in it doesn't match the
+// file and only the file is reported.
+func newTemplateError(file string, line int, column int, err error) TemplateError {
+	if files.IsMarkdownFile(file) {
+		return TemplateError{file, 0, 0, err}
+	}
+
+	if line > 0 {
+		if contents, readErr := os.ReadFile(file); readErr == nil {
+			_, _, bodyLine := files.ParseFrontMatterWithLine(contents)
+			line += bodyLine - 1
+		}
+	}
+
+	return TemplateError{file, line, column, err}
+}
+
+func (e TemplateError) Error() string {
+	switch {
+	case e.Line > 0 && e.Column > 0:
+		return fmt.Sprintf("%v:%d:%d: %v", e.File, e.Line, e.Column, e.Err)
+	case e.Line > 0:
+		return fmt.Sprintf("%v:%d: %v", e.File, e.Line, e.Err)
+	default:
+		return fmt.Sprintf("%v: %v", e.File, e.Err)
+	}
+}
+
+func (e TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// Check that the name of a template engine is one we support.
+func validateEngineName(name string) error {
+	if name == "" {
+		return nil
+	}
+	if _, ok := contentTemplates[name]; !ok {
+		return fmt.Errorf("Unknown template engine: %v", name)
+	}
+
+	return nil
+}
+
+// Get the name of the template engine used by the site.
+func (site Site) engineName() string {
+	if site.Config.TemplateEngine == "" {
+		return MustacheEngine
+	}
+
+	return site.Config.TemplateEngine
+}
+
+// Get the template engine used by the site.
+func (site Site) engine() Engine {
+	switch site.engineName() {
+	case GoEngine:
+		return goEngine{site.Partials, site.PartialPaths, site.Assets, site.Markdown, site.Strict}
+	default:
+		return mustacheEngine{site.Partials, site.PartialPaths, site.Assets, site.Markdown, site.Strict}
+	}
+}
+
+// Get a description of the source of a layout, for use in errors.
+func layoutSource(layout Layout) string {
+	if layout.Path == "" {
+		return fmt.Sprintf("%v layout", layout.Name)
+	}
+
+	return layout.Path
+}
+
+// Get a description of the source of a partial, for use in errors.
+func partialSource(partialPaths map[string]string, name string) string {
+	if filePath, ok := partialPaths[name]; ok {
+		return filePath
+	}
+
+	return fmt.Sprintf("%v partial", name)
+}
+
+// Provider for mustache partials, which wraps markdown partials so that they
+// are converted to HTML after being rendered and fails on missing partials
+// when in strict mode.
+type partialsProvider struct {
+	partials     map[string]string
+	partialPaths map[string]string
+	strict       bool
+}
+
+func (provider *partialsProvider) Get(name string) (string, error) {
+	data, ok := provider.partials[name]
+	if !ok {
+		if provider.strict {
+			return "", fmt.Errorf("missing partial %q", name)
+		}
+		return "", nil
+	}
+
+	if markdownPartial(provider.partialPaths, name) {
+		data = "{{#markdown}}" + data + "{{/markdown}}"
+	}
+
+	return data, nil
+}
+
+// Template engine using mustache templates.
+type mustacheEngine struct {
+	partials     map[string]string
+	partialPaths map[string]string
+	assets       map[string]Asset
+	markdown     *files.Markdown
+	strict       bool
+}
+
+// Pattern matching the errors mustache returns for missing variables and our
+// strict provider returns for missing partials.
+var missingPattern = regexp.MustCompile(`missing (variable|partial) "([^"]*)"`)
+
+// A mustache error which occurred in a partial, so that it can be reported
+// in the file of the partial rather than the template using it.
+type mustachePartialError struct {
+	name string
+	err  error
+}
+
+func (e mustachePartialError) Error() string {
+	return e.err.Error()
+}
+
+func (e mustachePartialError) Unwrap() error {
+	return e.err
+}
+
+// Pattern matching a mustache tag, capturing its type and name.
+var mustacheTagPattern = regexp.MustCompile(`\{\{\{?\s*([#^/>&]?)\s*([^}\s]+)\s*\}?\}\}`)
+
+// Get the line and column of a position in a piece of text.
+func textPosition(text string, offset int) (int, int) {
+	before := text[:offset]
+	line := strings.Count(before, "\n") + 1
+	column := offset - strings.LastIndex(before, "\n")
+
+	return line, column
+}
+
+// Find the position of the tag of the given type which matches the name in a
+// mustache template. Only variable tags can be missing a variable, and there's
+// no position if more than one tag matches as we can't tell which one it was.
+func mustacheTagPosition(template string, tagType mustache.TagType, name string) (int, int) {
+	var matches [][]int
+	for _, match := range mustacheTagPattern.FindAllStringSubmatchIndex(template, -1) {
+		symbol := template[match[2]:match[3]]
+		tagName := template[match[4]:match[5]]
+
+		if tagType == mustache.Partial {
+			if symbol == ">" && tagName == name {
+				matches = append(matches, match)
+			}
+			continue
+		}
+		if symbol != "" && symbol != "&" {
+			continue
+		}
+		for _, part := range strings.Split(tagName, ".") {
+			if part == name {
+				matches = append(matches, match)
+				break
+			}
+		}
+	}
+
+	if len(matches) != 1 {
+		return 0, 0
+	}
+
+	return textPosition(template, matches[0][0])
+}
+
+// Work out which template (or partial) a mustache error came from, and where
+// in it, as mustache itself doesn't keep track of it.
+func (engine mustacheEngine) locateError(err error, template string, source string) error {
+	var parseError mustache.ParseError
+	if errors.As(err, &parseError) {
+		candidates := []string{template}
+		sources := []string{source}
+
+		names := make([]string, 0, len(engine.partials))
+		for name := range engine.partials {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			candidates = append(candidates, engine.partials[name])
+			sources = append(sources, partialSource(engine.partialPaths, name))
+		}
+
+		for i, candidate := range candidates {
+			if _, parseErr := mustache.ParseString(candidate); parseErr != nil {
+				line := 0
+				if errors.As(parseErr, &parseError) {
+					// Remove the line from the message as it's relative to the template
+					line = parseError.Line
+					parseErr = errors.New(strings.TrimPrefix(parseErr.Error(), fmt.Sprintf("line %d: ", line)))
+				}
+				return newTemplateError(sources[i], line, 0, parseErr)
+			}
+		}
+	}
+
+	if match := missingPattern.FindStringSubmatch(err.Error()); match != nil {
+		tagType := mustache.Variable
+		if match[1] == "partial" {
+			tagType = mustache.Partial
+		}
+
+		// Errors in partials are wrapped in the partial they occurred in,
+		// so look for the innermost one
+		var partialError mustachePartialError
+		for inner := err; errors.As(inner, &partialError); inner = partialError.err {
+			template = engine.partials[partialError.name]
+			source = partialSource(engine.partialPaths, partialError.name)
+		}
+
+		line, column := mustacheTagPosition(template, tagType, match[2])
+		return newTemplateError(source, line, column, err)
+	}
+
+	return newTemplateError(source, 0, 0, err)
+}
+
+// Look up a name in a single mustache context the same way mustache does,
+// returning whether it was found.
+func lookupMustacheContext(value reflect.Value, name string) (reflect.Value, bool) {
+	for value.IsValid() {
+		if value.Kind() != reflect.Interface {
+			for i := 0; i < value.Type().NumMethod(); i++ {
+				method := value.Type().Method(i)
+				if method.Name == name && method.Type.NumIn() == 1 {
+					return value.Method(i).Call(nil)[0], true
+				}
+			}
+		}
+		if name == "." {
+			return value, true
+		}
+
+		switch value.Kind() {
+		case reflect.Ptr, reflect.Interface:
+			value = value.Elem()
+		case reflect.Struct:
+			field := value.FieldByName(name)
+			return field, field.IsValid()
+		case reflect.Map:
+			key := reflect.ValueOf(name)
+			if !key.Type().AssignableTo(value.Type().Key()) {
+				return reflect.Value{}, false
+			}
+			item := value.MapIndex(key)
+			return item, item.IsValid()
+		default:
+			return reflect.Value{}, false
+		}
+	}
+
+	return reflect.Value{}, false
+}
+
+// Look up a name in a mustache context chain (innermost first), following
+// dot notation and returning the same error mustache does when it's missing.
+func lookupMustache(chain []reflect.Value, name string) (reflect.Value, error) {
+	if name != "." && strings.Contains(name, ".") {
+		parts := strings.SplitN(name, ".", 2)
+		value, err := lookupMustache(chain, parts[0])
+		if err != nil {
+			return value, err
+		}
+		return lookupMustache([]reflect.Value{value}, parts[1])
+	}
+
+	for _, context := range chain {
+		if value, ok := lookupMustacheContext(context, name); ok {
+			return value, nil
+		}
+	}
+
+	return reflect.Value{}, fmt.Errorf("missing variable %q", name)
+}
+
+// Get the value a pointer or interface points to.
+func indirectValue(value reflect.Value) reflect.Value {
+	for value.IsValid() && (value.Kind() == reflect.Ptr || value.Kind() == reflect.Interface) {
+		value = value.Elem()
+	}
+
+	return value
+}
+
+// Whether a value counts as empty in a mustache section.
+func emptyMustacheValue(value reflect.Value) bool {
+	if !value.IsValid() || value.Interface() == nil {
+		return true
+	}
+
+	value = indirectValue(value)
+	switch value.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Array, reflect.Slice:
+		return value.Len() == 0
+	case reflect.String:
+		return len(strings.TrimSpace(value.String())) == 0
+	default:
+		return value.IsZero()
+	}
+}
+
+// A lambda which renders its content in the context of the section it's
+// used in.
+type mustacheLambda func(text string, render mustache.RenderFunc) (string, error)
+
+// Check that the variables used in mustache tags are in the context chain,
+// following sections and partials the same way they are rendered. Whether
+// mustache allows missing variables is a package global, so strict mode
+// checks them before rendering rather than changing it.
+func checkMustacheTags(tags []mustache.Tag, chain []reflect.Value, provider mustache.PartialProvider) error {
+	for _, tag := range tags {
+		switch tag.Type() {
+		case mustache.Variable:
+			if _, err := lookupMustache(chain, tag.Name()); err != nil {
+				return err
+			}
+		case mustache.Section, mustache.InvertedSection:
+			value, _ := lookupMustache(chain, tag.Name())
+			inverted := tag.Type() == mustache.InvertedSection
+			if emptyMustacheValue(value) != inverted {
+				continue
+			}
+
+			// Our lambdas render their content in the current context (any
+			// others are left to check it themselves), lists once for each
+			// item, and anything else in its own context
+			contexts := []reflect.Value{value}
+			switch item := indirectValue(value); {
+			case inverted:
+				contexts = []reflect.Value{chain[0]}
+			case item.Kind() == reflect.Func:
+				contexts = nil
+				if _, ok := item.Interface().(mustacheLambda); !ok {
+					continue
+				}
+				if err := checkMustacheTags(tag.Tags(), chain, provider); err != nil {
+					return err
+				}
+			case item.Kind() == reflect.Slice || item.Kind() == reflect.Array:
+				contexts = make([]reflect.Value, item.Len())
+				for i := range contexts {
+					contexts[i] = item.Index(i)
+				}
+			}
+
+			for _, context := range contexts {
+				if err := checkMustacheTags(tag.Tags(), append([]reflect.Value{context}, chain...), provider); err != nil {
+					return err
+				}
+			}
+		case mustache.Partial:
+			partial, err := provider.Get(tag.Name())
+			if err != nil {
+				return err
+			}
+
+			// Errors in parsing the partial are reported when it's rendered
+			parsed, err := mustache.ParseString(partial)
+			if err != nil {
+				continue
+			}
+			if err := checkMustacheTags(parsed.Tags(), chain, provider); err != nil {
+				return mustachePartialError{tag.Name(), err}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Render a mustache template with the given contexts (innermost first),
+// failing on missing variables when strict.
+func (engine mustacheEngine) render(template string, provider mustache.PartialProvider, contexts ...interface{}) (string, error) {
+	parsed, err := mustache.ParseStringPartials(template, provider)
+	if err != nil {
+		return "", err
+	}
+
+	if engine.strict {
+		chain := make([]reflect.Value, len(contexts))
+		for i, context := range contexts {
+			chain[i] = reflect.ValueOf(context)
+		}
+		if err := checkMustacheTags(parsed.Tags(), chain, provider); err != nil {
+			return "", err
+		}
+	}
+
+	return parsed.Render(contexts...)
+}
+
+func (engine mustacheEngine) Render(template string, source string, layouts []Layout, context map[string]interface{}) (string, error) {
+	provider := &partialsProvider{engine.partials, engine.partialPaths, engine.strict}
+	lambdas := engine.makeLambdas(provider, context)
+
+	content, err := engine.render(template, provider, context, lambdas)
+	if err != nil {
+		return "", engine.locateError(err, template, source)
+	}
+
+	for _, layout := range layouts {
+		content, err = engine.render(layout.Template, provider, map[string]string{"content": content}, context, lambdas)
+		if err != nil {
+			return "", engine.locateError(err, layout.Template, layoutSource(layout))
+		}
+	}
+
+	return content, nil
+}
+
+// Functions available in Go templates.
+var goTemplateFuncs = template.FuncMap{
+	"safeHTML": func(text string) template.HTML {
+		return template.HTML(text)
+	},
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"join":  strings.Join,
+	"dict": func(pairs ...interface{}) (map[string]interface{}, error) {
+		if len(pairs)%2 != 0 {
+			return nil, fmt.Errorf("dict requires an even number of arguments")
+		}
+		dict := make(map[string]interface{}, len(pairs)/2)
+		for i := 0; i < len(pairs); i += 2 {
+			key, ok := pairs[i].(string)
+			if !ok {
+				return nil, fmt.Errorf("dict keys must be strings: %v", pairs[i])
+			}
+			dict[key] = pairs[i+1]
+		}
+		return dict, nil
+	},
+}
+
+// Template engine using Go's html/template package, which escapes output
+// based on its context.
+type goEngine struct {
+	partials     map[string]string
+	partialPaths map[string]string
+	assets       map[string]Asset
+	markdown     *files.Markdown
+	strict       bool
+}
+
+// Pattern matching the position of an error in a Go template, capturing the
+// name of the template, the line and the column.
+var goErrorPattern = regexp.MustCompile(`template: ?(.*?):(\d+)(?::(\d+))?:`)
+
+// Get the line and column of an error in the Go template with the given name,
+// based on the error message.
+func goErrorPosition(err error, name string) (int, int) {
+	for _, match := range goErrorPattern.FindAllStringSubmatch(err.Error(), -1) {
+		if match[1] == name {
+			line, _ := strconv.Atoi(match[2])
+			column, _ := strconv.Atoi(match[3])
+			return line, column
+		}
+	}
+
+	return 0, 0
+}
+
+func (engine goEngine) Render(content string, source string, layouts []Layout, context map[string]interface{}) (string, error) {
+	templates := template.New("").Funcs(goTemplateFuncs)
+	if engine.strict {
+		templates.Option("missingkey=error")
+	}
+
+	// Render a partial with the given data (or the whole context), converting
+	// markdown partials to HTML
+	templates.Funcs(template.FuncMap{
+		"partial": func(name string, data ...interface{}) (template.HTML, error) {
+			if _, ok := engine.partials[name]; !ok {
+				if engine.strict {
+					return "", fmt.Errorf("missing partial %q", name)
+				}
+				return "", nil
+			}
+
+			var partialData interface{} = context
+			if len(data) > 0 {
+				partialData = data[0]
+			}
+
+			var buf bytes.Buffer
+			if err := templates.ExecuteTemplate(&buf, name, partialData); err != nil {
+				return "", err
+			}
+			if markdownPartial(engine.partialPaths, name) {
+				return template.HTML(engine.markdown.Render(buf.Bytes())), nil
+			}
+
+			return template.HTML(buf.String()), nil
+		},
+		"asset": func(name string) (string, error) {
+			return lookupAsset(engine.assets, name)
+		},
+		"markdown": func(text string) template.HTML {
+			return template.HTML(engine.markdown.Render([]byte(text)))
+		},
+	})
+
+	sources := map[string]string{"content": source}
+
+	// Make an error for the template with the given name
+	templateError := func(err error, name string) error {
+		line, column := goErrorPosition(err, name)
+		return newTemplateError(sources[name], line, column, err)
+	}
+
+	for name, partial := range engine.partials {
+		sources[name] = partialSource(engine.partialPaths, name)
+		if _, err := templates.New(name).Parse(partial); err != nil {
+			return "", templateError(err, name)
+		}
+	}
+
+	// Parse the outermost layout first, so that blocks can be overridden by
+	// the layouts inside it, and finally the template itself
+	for i := len(layouts) - 1; i >= 0; i-- {
+		name := "layout:" + layouts[i].Name
+		sources[name] = layoutSource(layouts[i])
+		if _, err := templates.New(name).Parse(layouts[i].Template); err != nil {
+			return "", templateError(err, name)
+		}
+	}
+	if _, err := templates.New("content").Parse(content); err != nil {
+		return "", templateError(err, "content")
+	}
+
+	// Use the template the error occurred in to find the source
+	locateError := func(err error, fallback string) error {
+		// Errors in partials are wrapped in the error of the template
+		// calling them, so look for the innermost one
+		var execError texttemplate.ExecError
+		var innermost *texttemplate.ExecError
+		for inner := err; errors.As(inner, &execError); inner = execError.Err {
+			if _, ok := sources[execError.Name]; ok {
+				found := execError
+				innermost = &found
+			}
+		}
+		if innermost != nil {
+			return templateError(*innermost, innermost.Name)
+		}
+		var escapeError *template.Error
+		if errors.As(err, &escapeError) && escapeError.Name != "" {
+			if _, ok := sources[escapeError.Name]; ok {
+				return templateError(err, escapeError.Name)
+			}
+		}
+
+		return templateError(err, fallback)
+	}
+
+	var buf bytes.Buffer
+	if err := templates.ExecuteTemplate(&buf, "content", context); err != nil {
+		return "", locateError(err, "content")
+	}
+
+	for _, layout := range layouts {
+		layoutContext := make(map[string]interface{}, len(context)+1)
+		for key, value := range context {
+			layoutContext[key] = value
+		}
+		layoutContext["content"] = template.HTML(buf.String())
+
+		buf.Reset()
+		if err := templates.ExecuteTemplate(&buf, "layout:"+layout.Name, layoutContext); err != nil {
+			return "", locateError(err, "layout:"+layout.Name)
+		}
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,343 @@
+package site
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cbroglie/mustache"
+	"github.com/michaelenger/brage/files"
+)
+
+func TestValidateEngineName(t *testing.T) {
+	for _, name := range []string{"", MustacheEngine, GoEngine} {
+		if err := validateEngineName(name); err != nil {
+			t.Fatalf("Unexpected error for %v: %v", name, err)
+		}
+	}
+
+	if err := validateEngineName("jinja"); err == nil {
+		t.Fatalf("Expected error but got nil")
+	}
+}
+
+func TestMustacheEngineRender(t *testing.T) {
+	engine := mustacheEngine{partials: map[string]string{"greeting": "Hello {{ name }}"}}
+	layouts := []Layout{
+		{Name: "inner", Template: "<main>{{{ content }}}</main>"},
+		{Name: "outer", Template: "<body>{{{ content }}}</body>"},
+	}
+	context := map[string]interface{}{"name": "<World>"}
+
+	result, err := engine.Render("{{> greeting }}!", "test.html", layouts, context)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := "<body><main>Hello &lt;World&gt;!</main></body>"
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+}
+
+func TestGoEngineRender(t *testing.T) {
+	engine := goEngine{partials: map[string]string{"greeting": "Hello {{ .name }}"}, markdown: newTestMarkdown(t, files.MarkdownConfig{})}
+	layouts := []Layout{
+		{Name: "inner", Template: "<main>{{ .content }}</main>"},
+		{Name: "outer", Template: `<title>{{ block "title" . }}Default{{ end }}</title><body>{{ .content }}</body>`},
+	}
+	context := map[string]interface{}{
+		"name": "<World>",
+		"data": DataMap{"words": []interface{}{"one", "two"}},
+	}
+
+	result, err := engine.Render(`{{ define "title" }}{{ upper .name }}{{ end }}{{ template "greeting" . }}!{{ range .data.words }} {{ . }}{{ end }}`, "test.html", layouts, context)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := "<title>&lt;WORLD&gt;</title><body><main>Hello &lt;World&gt;! one two</main></body>"
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+
+	result, err = engine.Render("{{ markdown .text }}", "test.html", nil, map[string]interface{}{"text": "_hi_"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result != "<p><em>hi</em></p>\n" {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, "<p><em>hi</em></p>\n")
+	}
+
+	_, err = engine.Render("{{ .broken", "test.html", nil, context)
+	if err == nil {
+		t.Fatalf("Expected error but got nil")
+	}
+}
+
+func TestLoadWithGoEngine(t *testing.T) {
+	dirPath := createExampleSite(t)
+	defer os.RemoveAll(dirPath)
+
+	configFile, err := os.OpenFile(path.Join(dirPath, "config.yaml"), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err = configFile.WriteString("template_engine: go\n"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	configFile.Close()
+	os.Remove(path.Join(dirPath, "layout-post.html"))
+	os.WriteFile(path.Join(dirPath, "layout.html"), []byte("<h1>{{ .site.title }}</h1>{{ .content }}"), 0644)
+
+	site, err := Load(dirPath, Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if site.Layouts[PostLayout].Template != "{{ .content }}" {
+		t.Fatalf("Incorrect site.Layouts[PostLayout]: %v", site.Layouts[PostLayout].Template)
+	}
+
+	post := Post{Path: "/test", Title: "A <Test>", Template: "<h2>{{ .post.title }}</h2>"}
+	result, err := post.Render(site)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := "<h1>Young Fatigue</h1><h2>A &lt;Test&gt;</h2>"
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+}
+
+func TestMustacheEngineStrict(t *testing.T) {
+	engine := mustacheEngine{
+		partials:     map[string]string{"greeting": "Hello {{ person.name }}"},
+		partialPaths: map[string]string{"greeting": "/site/partials/greeting.html"},
+		markdown:     newTestMarkdown(t, files.MarkdownConfig{}),
+		strict:       true,
+	}
+	layouts := []Layout{
+		{Name: "page", Path: "/site/layout-page.html", Template: "<main>{{{ content }}}{{ footer }}</main>"},
+	}
+
+	tests := []struct {
+		template string
+		context  map[string]interface{}
+		expected string
+	}{
+		{"{{ titel }}", map[string]interface{}{}, `/site/pages/test.html:1:1: missing variable "titel"`},
+		{"{{> greeting }}", map[string]interface{}{"person": map[string]string{}}, `/site/partials/greeting.html:1:7: missing variable "name"`},
+		{"{{> nope }}", map[string]interface{}{}, `/site/pages/test.html:1:1: missing partial "nope"`},
+		{"Hi", map[string]interface{}{}, `/site/layout-page.html:1:22: missing variable "footer"`},
+		{"{{#people}}{{ name }}{{/people}}{{> greeting }}", map[string]interface{}{"people": []map[string]string{{"name": "Bob"}}, "person": map[string]string{}}, `/site/partials/greeting.html:1:7: missing variable "name"`},
+		{"{{ name }}{{#partial}}greeting{{/partial}}", map[string]interface{}{"name": "Bob", "person": map[string]string{}}, `/site/partials/greeting.html:1:7: lambda "partial": missing variable "name"`},
+		{"{{#markdown}}{{ titel }}{{/markdown}}", map[string]interface{}{}, `/site/pages/test.html:1:14: missing variable "titel"`},
+		{"{{#people}}{{ name }}{{/people}}", map[string]interface{}{"people": []map[string]string{{"name": "Bob"}, {}}}, `/site/pages/test.html:1:12: missing variable "name"`},
+	}
+
+	for _, test := range tests {
+		_, err := engine.Render(test.template, "/site/pages/test.html", layouts, test.context)
+		if err == nil {
+			t.Fatalf("Expected error for %v but got nil", test.template)
+		}
+		if err.Error() != test.expected {
+			t.Fatalf("Result:\n%v\nExpected:\n%v", err, test.expected)
+		}
+	}
+
+	// Variables are looked up in sections the same way they are rendered
+	context := map[string]interface{}{
+		"footer": "Bye",
+		"site":   "Example",
+		"people": []map[string]string{{"name": "Bob"}},
+		"person": map[string]string{"name": "Alice"},
+	}
+	_, err := engine.Render("{{#people}}{{ name }} {{ site }}{{/people}}{{#person}}{{> greeting }}{{/person}}{{^nope}}{{ nope.name }}{{/nope}}{{#nope}}{{ nope.name }}{{/nope}}", "/site/pages/test.html", layouts, context)
+	if err == nil {
+		t.Fatalf("Expected error but got nil")
+	}
+	result, err := engine.Render("{{#people}}{{ name }} {{ site }}{{/people}}{{#person}}{{> greeting }}{{/person}}{{#nope}}{{ nope.name }}{{/nope}}", "/site/pages/test.html", layouts, context)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result != "<main>Bob ExampleHello AliceBye</main>" {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, "<main>Bob ExampleHello AliceBye</main>")
+	}
+
+	// Missing variables are allowed when not in strict mode
+	engine.strict = false
+	result, err = engine.Render("{{ titel }}{{> nope }}", "/site/pages/test.html", layouts, map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result != "<main></main>" {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, "<main></main>")
+	}
+}
+
+func TestMustacheEngineConcurrentStrict(t *testing.T) {
+	strict := mustacheEngine{strict: true}
+	lenient := mustacheEngine{}
+
+	// Templates can be rendered from within a render, including by an engine
+	// which isn't strict
+	context := map[string]interface{}{
+		"name": "Bob",
+		"nested": func(text string, render mustache.RenderFunc) (string, error) {
+			return strict.Render(text, "/site/nested.html", nil, map[string]interface{}{"name": "Alice"})
+		},
+		"lenient": func(text string, render mustache.RenderFunc) (string, error) {
+			return lenient.Render(text, "/site/lenient.html", nil, map[string]interface{}{})
+		},
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			result, err := strict.Render("{{ name }} {{#nested}}{{ name }}{{/nested}}{{#lenient}}{{ titel }}{{/lenient}}", "/site/test.html", nil, context)
+			if err == nil && result != "Bob Alice" {
+				err = fmt.Errorf("Incorrect result: %v", result)
+			}
+			if _, missingErr := strict.Render("{{ titel }}", "/site/test.html", nil, context); missingErr == nil {
+				err = fmt.Errorf("Expected error for missing variable")
+			}
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := lenient.Render("{{ titel }}", "/site/test.html", nil, context)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+}
+
+func TestGoEngineStrict(t *testing.T) {
+	engine := goEngine{
+		partials:     map[string]string{"greeting": "Hello {{ .person.name }}"},
+		partialPaths: map[string]string{"greeting": "/site/partials/greeting.html"},
+		strict:       true,
+	}
+	layouts := []Layout{
+		{Name: "page", Template: "<main>{{ .content }}{{ .footer }}</main>"},
+	}
+	context := map[string]interface{}{"person": map[string]interface{}{}, "footer": "Bye"}
+
+	_, err := engine.Render(`{{ template "greeting" . }}`, "/site/pages/test.html", layouts, context)
+	if err == nil {
+		t.Fatalf("Expected error but got nil")
+	}
+	if !strings.HasPrefix(err.Error(), "/site/partials/greeting.html:1:16: ") || !strings.Contains(err.Error(), `"name"`) {
+		t.Fatalf("Incorrect error: %v", err)
+	}
+
+	delete(context, "footer")
+	_, err = engine.Render("Hi", "/site/pages/test.html", layouts, context)
+	if err == nil {
+		t.Fatalf("Expected error but got nil")
+	}
+	if !strings.HasPrefix(err.Error(), "page layout:1:23: ") || !strings.Contains(err.Error(), `"footer"`) {
+		t.Fatalf("Incorrect error: %v", err)
+	}
+}
+
+func TestTemplateErrorPosition(t *testing.T) {
+	temporaryDirectory, err := os.MkdirTemp("", "engine")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.RemoveAll(temporaryDirectory)
+
+	filePath := path.Join(temporaryDirectory, "page.html")
+	template := "<h1>Title</h1>\n<p>{{ broken }</p>\n"
+	if err := os.WriteFile(filePath, []byte("---\ntitle: Test\n---\n"+template), 0644); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	_, err = mustacheEngine{}.Render(template, filePath, nil, map[string]interface{}{})
+	var templateError TemplateError
+	if !errors.As(err, &templateError) {
+		t.Fatalf("Expected template error but got: %v", err)
+	}
+	if templateError.File != filePath || templateError.Line != 5 {
+		t.Fatalf("Incorrect error position: %+v", templateError)
+	}
+
+	_, err = goEngine{}.Render("<h1>Title</h1>\n<p>{{ .broken }</p>\n", filePath, nil, map[string]interface{}{})
+	if !errors.As(err, &templateError) {
+		t.Fatalf("Expected template error but got: %v", err)
+	}
+	if templateError.File != filePath || templateError.Line != 5 {
+		t.Fatalf("Incorrect error position: %+v", templateError)
+	}
+
+	// Line endings in the front matter don't change the line
+	crlfPath := path.Join(temporaryDirectory, "crlf.html")
+	crlfTemplate := "\r\n\r\n\r\n<p>{{ broken }</p>\r\n"
+	if err := os.WriteFile(crlfPath, []byte("---\r\ntitle: Test\r\n---\r\n"+crlfTemplate), 0644); err != nil {
+		t.Fatalf("%v", err)
+	}
+	_, err = mustacheEngine{}.Render(crlfTemplate, crlfPath, nil, map[string]interface{}{})
+	if !errors.As(err, &templateError) {
+		t.Fatalf("Expected template error but got: %v", err)
+	}
+	if templateError.File != crlfPath || templateError.Line != 7 {
+		t.Fatalf("Incorrect error position: %+v", templateError)
+	}
+
+	// The position in rendered markdown doesn't match the file
+	markdownPath := path.Join(temporaryDirectory, "page.md")
+	if err := os.WriteFile(markdownPath, []byte("---\ntitle: Test\n---\n# Title\n\n{{ broken }\n"), 0644); err != nil {
+		t.Fatalf("%v", err)
+	}
+	_, err = mustacheEngine{}.Render(template, markdownPath, nil, map[string]interface{}{})
+	if !errors.As(err, &templateError) {
+		t.Fatalf("Expected template error but got: %v", err)
+	}
+	if templateError.File != markdownPath || templateError.Line != 0 || templateError.Column != 0 {
+		t.Fatalf("Incorrect error position: %+v", templateError)
+	}
+	if !strings.HasPrefix(err.Error(), markdownPath+": ") {
+		t.Fatalf("Incorrect error: %v", err)
+	}
+}
+
+func TestMustacheTagPosition(t *testing.T) {
+	template := "<p>\n  {{# post }}{{ post.title }}{{/ post }}\n  {{> footer }}\n  {{ name }} {{{ name }}}\n</p>"
+
+	tests := []struct {
+		tagType mustache.TagType
+		name    string
+		line    int
+		column  int
+	}{
+		{mustache.Variable, "post", 2, 14},
+		{mustache.Variable, "title", 2, 14},
+		{mustache.Partial, "footer", 3, 3},
+		{mustache.Variable, "footer", 0, 0},
+		{mustache.Variable, "name", 0, 0},
+	}
+
+	for _, test := range tests {
+		line, column := mustacheTagPosition(template, test.tagType, test.name)
+		if line != test.line || column != test.column {
+			t.Fatalf("Result for %v:\n%d:%d\nExpected:\n%d:%d", test.name, line, column, test.line, test.column)
+		}
+	}
+}
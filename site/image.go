@@ -0,0 +1,192 @@
+package site
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/michaelenger/brage/files"
+)
+
+// Directory inside the site where resized images are cached between builds.
+const imageCacheDirectory = ".cache/images"
+
+// A resized variant of an image, along with the file it's resized from and
+// the path it's available at when the site is built.
+type ImageVariant struct {
+	Source string
+	Width  int
+	Path   string
+}
+
+// An image in the assets along with its dimensions and resized variants.
+type Image struct {
+	Asset    Asset
+	Width    int
+	Height   int
+	Variants []ImageVariant
+}
+
+// Load the images in the assets which can be resized, along with the variants
+// for each of the configured widths which are smaller than the image.
+func loadImages(assets map[string]Asset, config files.ImageConfig) (map[string]Image, error) {
+	images := map[string]Image{}
+	if len(config.Widths) == 0 {
+		return images, nil
+	}
+	if err := config.Validate(); err != nil {
+		return images, err
+	}
+
+	widths := append([]int{}, config.Widths...)
+	sort.Ints(widths)
+
+	for name, asset := range assets {
+		if len(asset.Bundle) > 0 || files.ImageFormat(asset.Source) == "" {
+			continue
+		}
+
+		width, height, err := files.ImageSize(asset.Source)
+		if err != nil {
+			logger := log.Default()
+			logger.Printf("Unable to read image %v: %v", asset.Source, err)
+			continue
+		}
+
+		image := Image{Asset: asset, Width: width, Height: height}
+		for _, variantWidth := range widths {
+			if variantWidth <= 0 || variantWidth >= width {
+				continue
+			}
+			image.Variants = append(image.Variants, ImageVariant{
+				Source: asset.Source,
+				Width:  variantWidth,
+				Path:   config.VariantPath(asset.Path, variantWidth),
+			})
+		}
+		images[name] = image
+	}
+
+	return images, nil
+}
+
+// Find the image an image source points to, which is the path of an asset
+// either with or without its fingerprint.
+func (site Site) findImage(src string) (Image, bool) {
+	if !strings.HasPrefix(src, "/assets/") {
+		return Image{}, false
+	}
+
+	if image, ok := site.Images[assetName(src)]; ok {
+		return image, true
+	}
+	for _, image := range site.Images {
+		if image.Asset.Path == src {
+			return image, true
+		}
+	}
+
+	return Image{}, false
+}
+
+// Get the path an image source is available at, which is fingerprinted when
+// the image is in the assets and they are fingerprinted.
+func (site Site) imageSource(src string) string {
+	if image, ok := site.findImage(src); ok {
+		return image.Asset.Path
+	}
+
+	return src
+}
+
+// Make the attributes of an image: its srcset and sizes if it has been
+// resized, as well as its src, width and height. Images which aren't in the
+// assets get no attributes.
+func (site Site) imageAttributes(src string) map[string]string {
+	image, ok := site.findImage(src)
+	if !ok {
+		return nil
+	}
+
+	attributes := map[string]string{
+		"src":    image.Asset.Path,
+		"width":  fmt.Sprint(image.Width),
+		"height": fmt.Sprint(image.Height),
+	}
+
+	if len(image.Variants) > 0 {
+		candidates := []string{}
+		for _, variant := range image.Variants {
+			candidates = append(candidates, fmt.Sprintf("%v %dw", variant.Path, variant.Width))
+		}
+		candidates = append(candidates, fmt.Sprintf("%v %dw", image.Asset.Path, image.Width))
+
+		sizes := site.Config.Images.Sizes
+		if sizes == "" {
+			sizes = "100vw"
+		}
+
+		attributes["srcset"] = strings.Join(candidates, ", ")
+		attributes["sizes"] = sizes
+	}
+
+	return attributes
+}
+
+// Make the attributes of an image as HTML, in the same order as in Markdown,
+// apart from its src.
+func (site Site) imageAttributesHTML(src string) string {
+	attributes := site.imageAttributes(src)
+
+	html := ""
+	for _, name := range []string{"height", "sizes", "srcset", "width"} {
+		html += htmlAttribute(name, attributes[name])
+	}
+
+	return html
+}
+
+// Add the attributes of an image in the front matter to a context, as
+// "image_width", "image_height", "image_srcset" and "image_sizes", replacing
+// the "image" with its path. The image is either the path of an asset or its
+// name in the assets directory.
+func (site Site) addImageContext(context map[string]interface{}, src string) {
+	if src != "" && !strings.HasPrefix(src, "/") && !strings.Contains(src, "://") {
+		src = path.Join("/assets", src)
+	}
+
+	for name, value := range site.imageAttributes(src) {
+		if name == "src" {
+			context["image"] = value
+			continue
+		}
+		context["image_"+name] = value
+	}
+}
+
+// Get the contents of a resized variant of an image, resizing it unless it
+// has been cached by a previous build.
+func (site Site) ResizeImage(variant ImageVariant) ([]byte, error) {
+	cacheDirectory := path.Join(site.SourceDirectory, imageCacheDirectory)
+
+	return files.ResizeImageCached(cacheDirectory, variant.Source, variant.Width, site.Config.Images)
+}
+
+// Remove the resized images in the cache which aren't variants of the current
+// images, returning how many were removed.
+func (site Site) PruneImageCache() (int, error) {
+	names := map[string]bool{}
+	for _, image := range site.Images {
+		for _, variant := range image.Variants {
+			name, err := files.ImageCacheName(variant.Source, variant.Width, site.Config.Images)
+			if err != nil {
+				return 0, err
+			}
+			names[name] = true
+		}
+	}
+
+	return files.PruneImageCache(path.Join(site.SourceDirectory, imageCacheDirectory), names)
+}
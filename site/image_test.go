@@ -0,0 +1,201 @@
+package site
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/michaelenger/brage/files"
+)
+
+func createImageSite(t *testing.T) string {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 800, 600))); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	return writeTestSite(t, map[string]string{
+		"config.yaml":          "title: Images\nimages:\n  widths: [400, 1200, 200]\n  sizes: \"(min-width: 40em) 50vw, 100vw\"\n",
+		"assets/cat.png":       buf.String(),
+		"assets/notes.txt":     "Not an image",
+		"pages/index.md":       "![Cat](/assets/cat.png)",
+		"posts/first-post.md":  "---\nimage: cat.png\n---\n\nHello",
+		"layouts/default.html": "{{{ content }}}",
+		"layouts/post.html":    `<img src="{{ post.image }}" srcset="{{ post.image_srcset }}" width="{{ post.image_width }}">`,
+	})
+}
+
+func TestLoadImages(t *testing.T) {
+	dirPath := createImageSite(t)
+
+	assets, err := loadAssets([]string{dirPath}, nil, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	images, err := loadImages(assets, files.ImageConfig{Widths: []int{400, 1200, 200}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	source := path.Join(dirPath, "assets/cat.png")
+	expected := map[string]Image{
+		"cat.png": {
+			Asset:  assets["cat.png"],
+			Width:  800,
+			Height: 600,
+			Variants: []ImageVariant{
+				{Source: source, Width: 200, Path: "/assets/cat.200w.png"},
+				{Source: source, Width: 400, Path: "/assets/cat.400w.png"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(images, expected) {
+		t.Fatalf("Received:\n%+v\nExpected:\n%+v", images, expected)
+	}
+
+	// Nothing is resized unless there are widths
+	images, err = loadImages(assets, files.ImageConfig{})
+	if err != nil || len(images) != 0 {
+		t.Fatalf("Expected no images but got: %+v (%v)", images, err)
+	}
+
+	_, err = loadImages(assets, files.ImageConfig{Widths: []int{400}, Format: "webp"})
+	if err == nil || err.Error() != "Unknown image format: webp" {
+		t.Fatalf("Expected error for unknown format but got: %v", err)
+	}
+}
+
+func TestImageAttributes(t *testing.T) {
+	dirPath := createImageSite(t)
+
+	site, err := Load(dirPath, Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	srcset := "/assets/cat.200w.png 200w, /assets/cat.400w.png 400w, /assets/cat.png 800w"
+
+	result, err := site.Pages[0].Render(site)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := `<p><img src="/assets/cat.png" alt="Cat" height="600" sizes="(min-width: 40em) 50vw, 100vw" srcset="` + srcset + `" width="800"></p>
+`
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+
+	result, err = site.Posts[0].Render(site)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected = `<img src="/assets/cat.png" srcset="` + srcset + `" width="800">`
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+
+	if attributes := site.imageAttributes("/assets/notes.txt"); attributes != nil {
+		t.Fatalf("Expected no attributes but got: %+v", attributes)
+	}
+
+	// The variants are resized when needed and cached
+	variant, ok := site.FindAsset("/assets/cat.200w.png")
+	if !ok || site.AssetManifest()["cat.200w.png"] != "/assets/cat.200w.png" {
+		t.Fatalf("Unable to find image variant")
+	}
+	contents, err := variant.Contents()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if config, err := png.DecodeConfig(bytes.NewReader(contents)); err != nil || config.Width != 200 || config.Height != 150 {
+		t.Fatalf("Incorrect resized image: %+v (%v)", config, err)
+	}
+	entries, err := os.ReadDir(path.Join(dirPath, imageCacheDirectory))
+	if err != nil || len(entries) != 1 || !strings.HasSuffix(entries[0].Name(), ".png") {
+		t.Fatalf("Expected a single cached image but got: %v (%v)", entries, err)
+	}
+}
+
+func TestImageAttributesFingerprinted(t *testing.T) {
+	dirPath := createImageSite(t)
+
+	config := "title: Images\nfingerprint_assets: true\nimages:\n  widths: [400]\n"
+	if err := os.WriteFile(path.Join(dirPath, "config.yaml"), []byte(config), 0644); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	site, err := Load(dirPath, Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	imagePath := site.Assets["cat.png"].Path
+	if imagePath == "/assets/cat.png" {
+		t.Fatalf("Expected a fingerprinted image but got: %v", imagePath)
+	}
+	srcset := site.Config.Images.VariantPath(imagePath, 400) + " 400w, " + imagePath + " 800w"
+
+	result, err := site.Pages[0].Render(site)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := `<p><img src="` + imagePath + `" alt="Cat" height="600" sizes="100vw" srcset="` + srcset + `" width="800"></p>
+`
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+
+	result, err = site.Posts[0].Render(site)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected = `<img src="` + imagePath + `" srcset="` + srcset + `" width="800">`
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+
+	result, err = figureShortcode(site, Shortcode{Arguments: map[string]string{"src": "/assets/cat.png"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(result, `<img src="`+imagePath+`"`) {
+		t.Fatalf("Result:\n%v\nExpected the fingerprinted image", result)
+	}
+}
+
+func TestPruneImageCache(t *testing.T) {
+	dirPath := createImageSite(t)
+
+	site, err := Load(dirPath, Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	variant, _ := site.FindAsset("/assets/cat.400w.png")
+	if _, err := variant.Contents(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Images resized with another config are stale
+	cacheDirectory := path.Join(dirPath, imageCacheDirectory)
+	if err := os.WriteFile(path.Join(cacheDirectory, "stale.png"), []byte("stale"), 0644); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	removed, err := site.PruneImageCache()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if removed != 1 {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", removed, 1)
+	}
+	entries, err := os.ReadDir(cacheDirectory)
+	if err != nil || len(entries) != 1 || entries[0].Name() == "stale.png" {
+		t.Fatalf("Expected a single cached image but got: %v (%v)", entries, err)
+	}
+}
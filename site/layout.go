@@ -0,0 +1,164 @@
+package site
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/michaelenger/brage/files"
+)
+
+// Names of the built-in layouts.
+const (
+	DefaultLayout = "default"
+	PageLayout    = "page"
+	PostLayout    = "post"
+)
+
+// A layout which wraps the content of pages and posts, optionally extending
+// another layout.
+type Layout struct {
+	Name     string
+	Path     string
+	Template string
+	Parent   string
+}
+
+// Load a layout from a file, using its front matter to determine which
+// layout it extends.
+func loadLayout(name string, filePath string) (Layout, error) {
+	contents, err := os.ReadFile(filePath)
+	if err != nil {
+		return Layout{}, fmt.Errorf("Unable to load layout template at path: %v", filePath)
+	}
+
+	metadata, template := files.ParseFrontMatter(contents)
+
+	layout := Layout{
+		Name:     name,
+		Path:     filePath,
+		Template: string(template),
+	}
+	if val, ok := metadata["layout"].(string); ok {
+		layout.Parent = val
+	}
+
+	return layout, nil
+}
+
+// Load the layouts from the given directories, with layouts in later
+// directories replacing those in earlier ones. The "layout.html" file is the
+// default layout, "layout-<name>.html" files and files in the "layouts"
+// directory are named layouts. Any of the built-in layouts which are missing
+// use the template which only outputs the content.
+func loadLayouts(directories []string, contentTemplate string) (map[string]Layout, error) {
+	layouts := map[string]Layout{}
+
+	filePaths := map[string]string{}
+	for _, directory := range directories {
+		if entries, err := os.ReadDir(directory); err == nil {
+			for _, entry := range entries {
+				name := entry.Name()
+				if entry.IsDir() || path.Ext(name) != ".html" {
+					continue
+				}
+				if name == "layout.html" {
+					filePaths[DefaultLayout] = path.Join(directory, name)
+				} else if strings.HasPrefix(name, "layout-") {
+					filePaths[files.FileName(name)[7:]] = path.Join(directory, name)
+				}
+			}
+		}
+
+		layoutsDirectory := path.Join(directory, "layouts")
+		if _, err := os.Stat(layoutsDirectory); !os.IsNotExist(err) {
+			layoutFiles, err := files.ReadFiles(layoutsDirectory, "")
+			if err != nil {
+				return layouts, err
+			}
+			for name, file := range layoutFiles {
+				filePaths[name] = file.Path
+			}
+		}
+	}
+
+	for name, filePath := range filePaths {
+		layout, err := loadLayout(name, filePath)
+		if err != nil {
+			return layouts, err
+		}
+		layouts[name] = layout
+	}
+
+	if _, ok := layouts[DefaultLayout]; !ok {
+		layouts[DefaultLayout] = Layout{Name: DefaultLayout, Template: contentTemplate}
+	}
+	for _, name := range []string{PageLayout, PostLayout} {
+		if _, ok := layouts[name]; !ok {
+			layouts[name] = Layout{Name: name, Template: contentTemplate, Parent: DefaultLayout}
+		}
+	}
+
+	for name, layout := range layouts {
+		if layout.Parent == "" {
+			continue
+		}
+		if _, ok := layouts[layout.Parent]; !ok {
+			return layouts, fmt.Errorf("Layout %v extends unknown layout: %v", name, layout.Parent)
+		}
+	}
+
+	return layouts, nil
+}
+
+// Get the layout for a page based on its front matter or the default
+// layouts for its directory, falling back to the page layout.
+func (site Site) pageLayout(page Page) string {
+	if val, ok := page.Metadata["layout"].(string); ok {
+		return val
+	}
+
+	// Use the default of the most specific matching directory
+	prefixes := make([]string, 0, len(site.Config.DefaultLayouts))
+	for prefix := range site.Config.DefaultLayouts {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		return len(prefixes[i]) > len(prefixes[j])
+	})
+	for _, prefix := range prefixes {
+		directory := path.Clean("/" + prefix)
+		if page.Path == directory || strings.HasPrefix(page.Path, strings.TrimSuffix(directory, "/")+"/") {
+			return site.Config.DefaultLayouts[prefix]
+		}
+	}
+
+	return PageLayout
+}
+
+// Render a template from the given source file and wrap it in the named
+// layout and any layouts which that layout extends.
+func (site Site) renderInLayout(template string, source string, layoutName string, context map[string]interface{}) (string, error) {
+	layouts := []Layout{}
+
+	seen := map[string]bool{}
+	for name := layoutName; name != ""; {
+		if seen[name] {
+			return "", fmt.Errorf("Layout %v extends itself", name)
+		}
+		seen[name] = true
+
+		layout, ok := site.Layouts[name]
+		if !ok {
+			return "", fmt.Errorf("Unknown layout: %v", name)
+		}
+		layout.Name = name
+		layouts = append(layouts, layout)
+
+		name = layout.Parent
+	}
+
+	return site.engine().Render(template, source, layouts, context)
+}
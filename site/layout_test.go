@@ -0,0 +1,125 @@
+package site
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestLoadLayouts(t *testing.T) {
+	dirPath := createExampleSite(t)
+	defer os.RemoveAll(dirPath)
+
+	layoutsPath := path.Join(dirPath, "layouts")
+	if err := os.Mkdir(layoutsPath, 0755); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := os.WriteFile(path.Join(layoutsPath, "wide.html"), []byte("---\nlayout: page\n---\n<div class=\"wide\">{{{ content }}}</div>"), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	layouts, err := loadLayouts([]string{dirPath}, "{{{ content }}}")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(layouts) != 4 {
+		t.Fatalf("Incorrect layouts: %+v", layouts)
+	}
+	if layouts["wide"].Template != "<div class=\"wide\">{{{ content }}}</div>" {
+		t.Fatalf("Incorrect layouts[\"wide\"].Template: %v", layouts["wide"].Template)
+	}
+	if layouts["wide"].Parent != PageLayout {
+		t.Fatalf("Incorrect layouts[\"wide\"].Parent: %v", layouts["wide"].Parent)
+	}
+	if layouts["post"].Template != "This is the post layout" {
+		t.Fatalf("Incorrect layouts[\"post\"].Template: %v", layouts["post"].Template)
+	}
+}
+
+func TestLoadLayoutsUnknownParent(t *testing.T) {
+	dirPath := createExampleSite(t)
+	defer os.RemoveAll(dirPath)
+
+	if err := os.WriteFile(path.Join(dirPath, "layout-post.html"), []byte("---\nlayout: nope\n---\n{{{ content }}}"), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	_, err := loadLayouts([]string{dirPath}, "{{{ content }}}")
+	if err == nil {
+		t.Fatalf("Expected error but got nil")
+	}
+}
+
+func TestPageLayout(t *testing.T) {
+	site := Site{
+		Config: SiteConfig{
+			DefaultLayouts: map[string]string{
+				"docs":          "docs",
+				"/docs/api/":    "api",
+				"/docs-archive": "archive",
+			},
+		},
+	}
+
+	var tests map[string]string = map[string]string{
+		"/":                  PageLayout,
+		"/about":             PageLayout,
+		"/docs":              "docs",
+		"/docs/install":      "docs",
+		"/docs/api/v1":       "api",
+		"/docs-archive/old":  "archive",
+		"/documents/example": PageLayout,
+	}
+
+	for pagePath, expected := range tests {
+		result := site.pageLayout(Page{Path: pagePath})
+		if result != expected {
+			t.Fatalf("Layout for %v\nResult:\n%v\nExpected:\n%v", pagePath, result, expected)
+		}
+	}
+
+	result := site.pageLayout(Page{Path: "/docs", Metadata: map[string]interface{}{"layout": "wide"}})
+	if result != "wide" {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, "wide")
+	}
+}
+
+func TestRenderInLayout(t *testing.T) {
+	site := Site{
+		Layouts: map[string]Layout{
+			DefaultLayout: {Template: "<body>{{{ content }}}</body>"},
+			PageLayout:    {Template: "<main>{{{ content }}}</main>", Parent: DefaultLayout},
+			"wide":        {Template: "<div>{{ title }}: {{{ content }}}</div>", Parent: PageLayout},
+			"loop":        {Template: "{{{ content }}}", Parent: "loop"},
+		},
+	}
+	context := map[string]interface{}{"title": "Test"}
+
+	result, err := site.renderInLayout("<p>{{ title }}</p>", "test.html", "wide", context)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "<body><main><div>Test: <p>Test</p></div></main></body>"
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+
+	result, err = site.renderInLayout("<p>{{ title }}</p>", "test.html", "", context)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result != "<p>Test</p>" {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, "<p>Test</p>")
+	}
+
+	_, err = site.renderInLayout("", "test.html", "loop", context)
+	if err == nil {
+		t.Fatalf("Expected error but got nil")
+	}
+
+	_, err = site.renderInLayout("", "test.html", "nope", context)
+	if err == nil {
+		t.Fatalf("Expected error but got nil")
+	}
+}
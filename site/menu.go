@@ -0,0 +1,174 @@
+package site
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/michaelenger/brage/files"
+)
+
+// An item in a navigation menu.
+type MenuItem struct {
+	Name       string
+	Url        string
+	Identifier string
+	Parent     string
+	Weight     int
+}
+
+// Get the names of the menus a page has opted into.
+func menuNames(metadata map[string]interface{}) []string {
+	var names []string
+
+	switch val := metadata["menu"].(type) {
+	case string:
+		names = append(names, val)
+	case []interface{}:
+		for _, name := range val {
+			if name, ok := name.(string); ok {
+				names = append(names, name)
+			}
+		}
+	}
+
+	return names
+}
+
+// Collect the menu items defined in the config and in the front matter of
+// the pages.
+func collectMenus(config SiteConfig, pages []Page) map[string][]MenuItem {
+	menus := map[string][]MenuItem{}
+	for name, items := range config.Menus {
+		menus[name] = append(menus[name], items...)
+	}
+
+	for _, page := range pages {
+		names := menuNames(page.Metadata)
+		if len(names) == 0 {
+			continue
+		}
+
+		item := MenuItem{
+			Name:       page.Title(),
+			Url:        page.Path,
+			Identifier: files.PathToIdentifier(page.Path),
+		}
+		if val, ok := page.Metadata["menu_title"].(string); ok {
+			item.Name = val
+		}
+		if val, ok := page.Metadata["menu_parent"].(string); ok {
+			item.Parent = val
+		}
+		if val, ok := page.Metadata["weight"].(int); ok {
+			item.Weight = val
+		}
+
+		for _, name := range names {
+			menus[name] = append(menus[name], item)
+		}
+	}
+
+	return menus
+}
+
+// Check that none of the menu items are their own ancestor, which would make
+// the tree of items endless.
+func validateMenus(menus map[string][]MenuItem) error {
+	names := make([]string, 0, len(menus))
+	for name := range menus {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		parents := map[string][]string{}
+		for _, item := range menus[name] {
+			if item.Identifier != "" && item.Parent != "" {
+				parents[item.Identifier] = append(parents[item.Identifier], item.Parent)
+			}
+		}
+
+		// Walk the parent chains of each item, stopping at those already checked
+		checked := map[string]bool{}
+		var walk func(identifier string, visiting map[string]bool) bool
+		walk = func(identifier string, visiting map[string]bool) bool {
+			if visiting[identifier] {
+				return false
+			}
+			if checked[identifier] {
+				return true
+			}
+			visiting[identifier] = true
+			for _, parent := range parents[identifier] {
+				if !walk(parent, visiting) {
+					return false
+				}
+			}
+			delete(visiting, identifier)
+			checked[identifier] = true
+			return true
+		}
+
+		for _, item := range menus[name] {
+			if item.Identifier != "" && !walk(item.Identifier, map[string]bool{}) {
+				return fmt.Errorf("Menu item in %v is its own parent: %v", name, item.Identifier)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Build the tree of menu items below a parent, marking the item for the
+// current path as active.
+func makeMenuTree(items []MenuItem, parent string, currentPath string) ([]map[string]interface{}, bool) {
+	children := []MenuItem{}
+	for _, item := range items {
+		if item.Parent == parent {
+			children = append(children, item)
+		}
+	}
+
+	sort.SliceStable(children, func(i, j int) bool {
+		if children[i].Weight != children[j].Weight {
+			return children[i].Weight < children[j].Weight
+		}
+		return children[i].Name < children[j].Name
+	})
+
+	tree := make([]map[string]interface{}, len(children))
+	containsActive := false
+	for i, item := range children {
+		var subtree []map[string]interface{}
+		activeChild := false
+		if item.Identifier != "" {
+			subtree, activeChild = makeMenuTree(items, item.Identifier, currentPath)
+		}
+
+		active := item.Url == currentPath
+		containsActive = containsActive || active || activeChild
+
+		tree[i] = map[string]interface{}{
+			"name":         item.Name,
+			"url":          item.Url,
+			"identifier":   item.Identifier,
+			"weight":       item.Weight,
+			"active":       active,
+			"active_child": activeChild,
+			"children":     subtree,
+			"has_children": len(subtree) > 0,
+		}
+	}
+
+	return tree, containsActive
+}
+
+// Make the menus used in the site context for the page at the given path.
+func (site Site) makeMenusContext(currentPath string) map[string]interface{} {
+	menus := map[string]interface{}{}
+	for name, items := range site.Menus {
+		menus[name], _ = makeMenuTree(items, "", currentPath)
+	}
+
+	return menus
+}
@@ -0,0 +1,180 @@
+package site
+
+import (
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCollectMenus(t *testing.T) {
+	config := SiteConfig{
+		Menus: map[string][]MenuItem{
+			"main": {
+				{Name: "Home", Url: "/", Weight: 1},
+			},
+		},
+	}
+	pages := []Page{
+		{Path: "/about", Metadata: map[string]interface{}{"menu": "main", "weight": 10}},
+		{Path: "/docs", Metadata: map[string]interface{}{"menu": []interface{}{"main", "footer"}, "menu_title": "Documentation"}},
+		{Path: "/docs/install", Metadata: map[string]interface{}{"menu": "main", "title": "Installing", "menu_parent": "docs"}},
+		{Path: "/hidden"},
+	}
+
+	result := collectMenus(config, pages)
+	expected := map[string][]MenuItem{
+		"main": {
+			{Name: "Home", Url: "/", Weight: 1},
+			{Name: "About", Url: "/about", Identifier: "about", Weight: 10},
+			{Name: "Documentation", Url: "/docs", Identifier: "docs"},
+			{Name: "Installing", Url: "/docs/install", Identifier: "docs-install", Parent: "docs"},
+		},
+		"footer": {
+			{Name: "Documentation", Url: "/docs", Identifier: "docs"},
+		},
+	}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("Received:\n%+v\nExpected:\n%+v", result, expected)
+	}
+}
+
+func TestMakeMenuTree(t *testing.T) {
+	items := []MenuItem{
+		{Name: "Home", Url: "/", Weight: 1},
+		{Name: "About", Url: "/about", Identifier: "about", Weight: 10},
+		{Name: "Docs", Url: "/docs", Identifier: "docs", Weight: 5},
+		{Name: "Install", Url: "/docs/install", Identifier: "docs-install", Parent: "docs"},
+	}
+
+	result, containsActive := makeMenuTree(items, "", "/docs/install")
+	expected := []map[string]interface{}{
+		{
+			"name":         "Home",
+			"url":          "/",
+			"identifier":   "",
+			"weight":       1,
+			"active":       false,
+			"active_child": false,
+			"children":     []map[string]interface{}(nil),
+			"has_children": false,
+		},
+		{
+			"name":         "Docs",
+			"url":          "/docs",
+			"identifier":   "docs",
+			"weight":       5,
+			"active":       false,
+			"active_child": true,
+			"children": []map[string]interface{}{
+				{
+					"name":         "Install",
+					"url":          "/docs/install",
+					"identifier":   "docs-install",
+					"weight":       0,
+					"active":       true,
+					"active_child": false,
+					"children":     []map[string]interface{}{},
+					"has_children": false,
+				},
+			},
+			"has_children": true,
+		},
+		{
+			"name":         "About",
+			"url":          "/about",
+			"identifier":   "about",
+			"weight":       10,
+			"active":       false,
+			"active_child": false,
+			"children":     []map[string]interface{}{},
+			"has_children": false,
+		},
+	}
+
+	if !containsActive {
+		t.Fatalf("Expected tree to contain the active item")
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("Received:\n%+v\nExpected:\n%+v", result, expected)
+	}
+}
+
+func TestPageRenderWithMenu(t *testing.T) {
+	page := Page{
+		Path:     "/about",
+		Template: `{{ #site.menus.main }}<a href="{{ url }}"{{ #active }} class="active"{{ /active }}>{{ name }}</a>{{ /site.menus.main }}`,
+	}
+	site := Site{
+		Layouts: map[string]Layout{PageLayout: {Template: "{{{ content }}}"}},
+		Menus: map[string][]MenuItem{
+			"main": {
+				{Name: "Home", Url: "/", Weight: 1},
+				{Name: "About", Url: "/about", Weight: 2},
+			},
+		},
+	}
+
+	result, err := page.Render(site)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := `<a href="/">Home</a><a href="/about" class="active">About</a>`
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+}
+
+func TestValidateMenus(t *testing.T) {
+	valid := map[string][]MenuItem{
+		"main": {
+			{Name: "Docs", Identifier: "docs"},
+			{Name: "Install", Identifier: "install", Parent: "docs"},
+			{Name: "Linux", Identifier: "linux", Parent: "install"},
+			{Name: "Orphan", Identifier: "orphan", Parent: "missing"},
+		},
+	}
+	if err := validateMenus(valid); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	selfParent := map[string][]MenuItem{
+		"main": {
+			{Name: "Docs", Identifier: "docs", Parent: "docs"},
+		},
+	}
+	err := validateMenus(selfParent)
+	if err == nil || err.Error() != "Menu item in main is its own parent: docs" {
+		t.Fatalf("Expected error for self-parent but got: %v", err)
+	}
+
+	cycle := map[string][]MenuItem{
+		"footer": {
+			{Name: "Home", Identifier: "home"},
+			{Name: "A", Identifier: "a", Parent: "b"},
+			{Name: "B", Identifier: "b", Parent: "a"},
+		},
+	}
+	err = validateMenus(cycle)
+	if err == nil || err.Error() != "Menu item in footer is its own parent: a" {
+		t.Fatalf("Expected error for cycle but got: %v", err)
+	}
+}
+
+func TestLoadMenuCycle(t *testing.T) {
+	dirPath := createExampleSite(t)
+	defer os.RemoveAll(dirPath)
+
+	config := "title: Menus\nmenus:\n  main:\n    - name: A\n      identifier: a\n      parent: b\n    - name: B\n      identifier: b\n      parent: a\n"
+	if err := os.WriteFile(path.Join(dirPath, "config.yaml"), []byte(config), 0644); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	_, err := Load(dirPath, Options{})
+	if err == nil || !strings.Contains(err.Error(), "is its own parent: a") {
+		t.Fatalf("Expected error for cycle but got: %v", err)
+	}
+}
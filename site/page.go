@@ -1,28 +1,149 @@
 package site
 
 import (
-	"github.com/cbroglie/mustache"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
 	"github.com/michaelenger/brage/files"
 )
 
 type Page struct {
-	Path     string
-	Template string
+	Path      string
+	Source    string
+	Template  string
+	Headings  []files.Heading
+	Backlinks []Link
+	Metadata  map[string]interface{}
+	Item      interface{}
+}
+
+// Pattern matching a placeholder in a page path, e.g. "[slug]".
+var placeholderPattern = regexp.MustCompile(`\[([^\]]+)\]`)
+
+// Get a value from a data record.
+func recordValue(record interface{}, key string) (interface{}, bool) {
+	switch r := record.(type) {
+	case DataMap:
+		value, ok := r[key]
+		return value, ok
+	case map[interface{}]interface{}:
+		value, ok := r[key]
+		return value, ok
+	case map[string]interface{}:
+		value, ok := r[key]
+		return value, ok
+	case map[string]string:
+		value, ok := r[key]
+		return value, ok
+	}
+
+	return nil, false
+}
+
+// Get the records from a data file used to generate pages.
+func loadRecords(filePath string) ([]interface{}, error) {
+	data, err := loadDataFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	switch d := data.(type) {
+	case []interface{}:
+		return d, nil
+	case []map[string]string:
+		records := make([]interface{}, len(d))
+		for i := range d {
+			records[i] = d[i]
+		}
+		return records, nil
+	}
+
+	return nil, fmt.Errorf("Data file does not contain a list of records: %v", filePath)
+}
+
+// Make the pages for a page file, generating one page per record if the
+// page has a data source.
+func makePages(siteDirectory string, name string, file files.File, markdown *files.Markdown) ([]Page, error) {
+	metadata, template, headings := file.Parse(markdown)
+
+	source, ok := metadata["source"].(string)
+	if !ok {
+		return []Page{{Path: name, Source: file.Path, Template: template, Headings: headings, Metadata: metadata}}, nil
+	}
+
+	if !placeholderPattern.MatchString(name) {
+		return nil, fmt.Errorf("Page with a data source has no placeholder in its path: %v", file.Path)
+	}
+
+	records, err := loadRecords(path.Join(siteDirectory, source))
+	if err != nil {
+		return nil, err
+	}
+
+	pages := make([]Page, len(records))
+	for i, record := range records {
+		var missing error
+		pagePath := placeholderPattern.ReplaceAllStringFunc(name, func(placeholder string) string {
+			key := strings.Trim(placeholder, "[]")
+			value, ok := recordValue(record, key)
+			if !ok {
+				missing = fmt.Errorf("Record %d in %v is missing the field: %v", i, source, key)
+				return ""
+			}
+			return slugify(fmt.Sprint(value))
+		})
+		if missing != nil {
+			return nil, missing
+		}
+
+		pages[i] = Page{
+			Path:     path.Clean(pagePath),
+			Source:   file.Path,
+			Template: template,
+			Headings: headings,
+			Metadata: metadata,
+			Item:     record,
+		}
+	}
+
+	return pages, nil
+}
+
+// Get the title of the page, either from the front matter or inferred based
+// on the path.
+func (page Page) Title() string {
+	if val, ok := page.Metadata["title"].(string); ok {
+		return val
+	}
+
+	return files.PathToTitle(page.Path)
 }
 
 // Create the context used when rendering a page.
 func (page Page) makeContext(site Site) map[string]interface{} {
-	pageContext := map[string]string{
-		"path":       page.Path,
-		"template":   page.Template,
-		"title":      files.PathToTitle(page.Path),
-		"identifier": files.PathToIdentifier(page.Path),
+	pageContext := map[string]interface{}{
+		"path":          page.Path,
+		"template":      page.Template,
+		"title":         page.Title(),
+		"identifier":    files.PathToIdentifier(page.Path),
+		"toc":           makeTocContext(page.Headings, site.Config.Toc),
+		"backlinks":     makeBacklinksContext(page.Backlinks),
+		"has_backlinks": len(page.Backlinks) > 0,
 	}
+	if site.PageTree != nil {
+		site.PageTree.addPageContext(page.Path, pageContext)
+	}
+
+	siteContext := site.MakeContext()
+	siteContext["menus"] = site.makeMenusContext(page.Path)
 
 	return map[string]interface{}{
-		"site": site.MakeContext(),
+		"site": siteContext,
 		"page": pageContext,
 		"data": site.Config.Data,
+		"item": page.Item,
 	}
 }
 
@@ -30,7 +151,5 @@ func (page Page) makeContext(site Site) map[string]interface{} {
 func (page Page) Render(site Site) (string, error) {
 	context := page.makeContext(site)
 
-	partialsProvider := &mustache.StaticProvider{site.Partials}
-
-	return mustache.RenderInLayoutPartials(page.Template, site.Layouts[PageLayout], partialsProvider, context)
+	return site.renderInLayout(page.Template, page.Source, site.pageLayout(page), context)
 }
@@ -2,20 +2,24 @@ package site
 
 import (
 	"os"
+	"path"
+	"reflect"
 	"regexp"
 	"testing"
+
+	"github.com/michaelenger/brage/files"
 )
 
 var testConfig = SiteConfig{
-	"Test Site",
-	"This is just a test.",
-	"test.jpg",
-	"Person McPersonface",
-	"https://example.org/",
-	map[string]string{
+	Title:       "Test Site",
+	Description: "This is just a test.",
+	Image:       "test.jpg",
+	Author:      "Person McPersonface",
+	RootUrl:     "https://example.org/",
+	Redirects: map[string]string{
 		"/example": "https://example.org/",
 	},
-	DataMap{
+	Data: DataMap{
 		"skills": []string{
 			"one", "two", "three",
 		},
@@ -31,8 +35,8 @@ func TestPageRender(t *testing.T) {
 	}
 
 	page := Page{
-		"/example",
-		`<h1>{{ page.title }}</h1>
+		Path: "/example",
+		Template: `<h1>{{ page.title }}</h1>
 		{{ #data.skills }}
 			<p>{{ . }}</p>
 		{{ /data.skills }}
@@ -57,21 +61,23 @@ func TestPageRender(t *testing.T) {
 		</body>`
 
 	site := Site{
-		testConfig,
-		temporaryDirectory,
-		map[LayoutType]string{
-			PageLayout: `<head>
-				<title>{{ site.title }}</title>
-			</head>
-			<body>
-				{{{ content }}}
-			</body>`,
+		Config:          testConfig,
+		SourceDirectory: temporaryDirectory,
+		Layouts: map[string]Layout{
+			PageLayout: {
+				Template: `<head>
+					<title>{{ site.title }}</title>
+				</head>
+				<body>
+					{{{ content }}}
+				</body>`,
+			},
 		},
-		[]Page{},
-		map[string]string{
+		Pages: []Page{},
+		Partials: map[string]string{
 			"temp": `<em>This is from a template</em>`,
 		},
-		[]Post{},
+		Posts: []Post{},
 	}
 
 	result, err := page.Render(site)
@@ -86,3 +92,86 @@ func TestPageRender(t *testing.T) {
 		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
 	}
 }
+
+func TestMakePages(t *testing.T) {
+	temporaryDirectory, err := os.MkdirTemp("", "examplesite")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer os.RemoveAll(temporaryDirectory)
+
+	err = os.Mkdir(path.Join(temporaryDirectory, "data"), 0755)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	err = os.WriteFile(path.Join(temporaryDirectory, "data", "people.yaml"), []byte(`
+- slug: alice
+  name: Alice
+- slug: Bob Bobson
+  name: Bob
+`), 0644)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	file := files.File{
+		Type:    files.HtmlFile,
+		Path:    path.Join(temporaryDirectory, "pages", "people", "[slug].html"),
+		Content: []byte("---\nsource: data/people.yaml\n---\n<h1>{{ item.name }}</h1>"),
+	}
+
+	pages, err := makePages(temporaryDirectory, "/people/[slug]", file, newTestMarkdown(t, files.MarkdownConfig{}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(pages) != 2 {
+		t.Fatalf("Incorrect pages: %+v", pages)
+	}
+	if pages[0].Path != "/people/alice" {
+		t.Fatalf("Incorrect pages[0].Path: %v", pages[0].Path)
+	}
+	if pages[1].Path != "/people/bob-bobson" {
+		t.Fatalf("Incorrect pages[1].Path: %v", pages[1].Path)
+	}
+	if pages[1].Template != "<h1>{{ item.name }}</h1>" {
+		t.Fatalf("Incorrect pages[1].Template: %v", pages[1].Template)
+	}
+
+	result, err := pages[1].Render(Site{Layouts: map[string]Layout{PageLayout: {Template: "{{{ content }}}"}}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result != "<h1>Bob</h1>" {
+		t.Fatalf("Incorrect render result: %v", result)
+	}
+
+	file.Content = []byte("---\nsource: data/people.yaml\n---\n<h1>{{ item.name }}</h1>")
+	_, err = makePages(temporaryDirectory, "/people/[name]/[id]", file, newTestMarkdown(t, files.MarkdownConfig{}))
+	if err == nil {
+		t.Fatalf("Expected error but got nil")
+	}
+
+	_, err = makePages(temporaryDirectory, "/people/list", file, newTestMarkdown(t, files.MarkdownConfig{}))
+	if err == nil {
+		t.Fatalf("Expected error but got nil")
+	}
+}
+
+func TestMakePagesWithoutSource(t *testing.T) {
+	file := files.File{
+		Type:    files.HtmlFile,
+		Path:    "/tmp/pages/about.html",
+		Content: []byte("<p>About</p>"),
+	}
+
+	pages, err := makePages("/tmp", "/about", file, newTestMarkdown(t, files.MarkdownConfig{}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []Page{{Path: "/about", Source: "/tmp/pages/about.html", Template: "<p>About</p>"}}
+	if !reflect.DeepEqual(pages, expected) {
+		t.Fatalf("Received:\n%+v\nExpected:\n%+v", pages, expected)
+	}
+}
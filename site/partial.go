@@ -0,0 +1,160 @@
+package site
+
+import (
+	"fmt"
+	"html"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/cbroglie/mustache"
+)
+
+// Pattern matching an argument passed to a partial or shortcode, e.g.
+// `title="Hello"`.
+var argumentPattern = regexp.MustCompile(`([\w-]+)=("(?:[^"\\]|\\.)*"|\S+)`)
+
+// Check whether a partial was loaded from a markdown file, meaning it needs
+// to be converted to HTML after being rendered.
+func markdownPartial(partialPaths map[string]string, name string) bool {
+	switch path.Ext(partialPaths[name]) {
+	case ".markdown", ".md":
+		return true
+	default:
+		return false
+	}
+}
+
+// Remove the indentation which mustache adds to every line after the first
+// when a partial is indented, as indented markdown is treated as code.
+func dedentMarkdown(text string) string {
+	lines := strings.Split(text, "\n")
+
+	indent := -1
+	for _, line := range lines[1:] {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lineIndent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent == -1 || lineIndent < indent {
+			indent = lineIndent
+		}
+	}
+	if indent <= 0 {
+		return text
+	}
+
+	for i := 1; i < len(lines); i++ {
+		if len(lines[i]) >= indent {
+			lines[i] = lines[i][indent:]
+		} else {
+			lines[i] = strings.TrimLeft(lines[i], " \t")
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Parse arguments in the form `key="value" other=value`.
+func parseArguments(text string) (map[string]string, error) {
+	arguments := map[string]string{}
+
+	rest := strings.TrimSpace(text)
+	for rest != "" {
+		match := argumentPattern.FindStringSubmatchIndex(rest)
+		if match == nil || match[0] != 0 {
+			return nil, fmt.Errorf("Invalid arguments: %v", rest)
+		}
+
+		key := rest[match[2]:match[3]]
+		value := rest[match[4]:match[5]]
+		if strings.HasPrefix(value, `"`) {
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid value for %v: %v", key, value)
+			}
+			value = unquoted
+		}
+		arguments[key] = value
+
+		rest = strings.TrimSpace(rest[match[1]:])
+	}
+
+	return arguments, nil
+}
+
+// Parse the name of a partial and the arguments passed to it, in the form
+// `name key="value" other=value`.
+func parsePartialArguments(text string) (string, map[string]interface{}, error) {
+	text = strings.TrimSpace(text)
+	name, rest, _ := strings.Cut(text, " ")
+	if name == "" {
+		return "", nil, fmt.Errorf("Missing partial name")
+	}
+
+	parsed, err := parseArguments(rest)
+	if err != nil {
+		return "", nil, fmt.Errorf("Unable to parse arguments for partial %v: %v", name, err)
+	}
+
+	// The arguments have already been rendered, so undo the escaping to avoid
+	// escaping the values twice
+	arguments := make(map[string]interface{}, len(parsed))
+	for key, value := range parsed {
+		arguments[key] = html.UnescapeString(value)
+	}
+
+	return name, arguments, nil
+}
+
+// Make the lambdas available in mustache templates: "markdown" which converts
+// its rendered content to HTML, and "partial" which renders a partial with
+// the arguments given to it on top of the context.
+func (engine mustacheEngine) makeLambdas(provider mustache.PartialProvider, context map[string]interface{}) map[string]interface{} {
+	lambdas := map[string]interface{}{}
+
+	lambdas["markdown"] = mustacheLambda(func(text string, render mustache.RenderFunc) (string, error) {
+		rendered, err := render(text)
+		if err != nil {
+			return "", err
+		}
+
+		return engine.markdown.Render([]byte(dedentMarkdown(rendered))), nil
+	})
+
+	lambdas["partial"] = mustacheLambda(func(text string, render mustache.RenderFunc) (string, error) {
+		rendered, err := render(text)
+		if err != nil {
+			return "", err
+		}
+
+		name, arguments, err := parsePartialArguments(rendered)
+		if err != nil {
+			return "", err
+		}
+
+		template, err := provider.Get(name)
+		if err != nil {
+			return "", err
+		}
+
+		rendered, err = engine.render(template, provider, arguments, context, lambdas)
+		if err != nil {
+			return "", mustachePartialError{name, err}
+		}
+
+		return rendered, nil
+	})
+
+	lambdas["asset"] = mustacheLambda(func(text string, render mustache.RenderFunc) (string, error) {
+		rendered, err := render(text)
+		if err != nil {
+			return "", err
+		}
+
+		return lookupAsset(engine.assets, rendered)
+	})
+
+	return lambdas
+}
@@ -0,0 +1,138 @@
+package site
+
+import (
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/michaelenger/brage/files"
+)
+
+func TestParsePartialArguments(t *testing.T) {
+	name, arguments, err := parsePartialArguments(` components/card title="A &amp; \"B\"" image=cat.png  count=2 `)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if name != "components/card" {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", name, "components/card")
+	}
+
+	expected := map[string]interface{}{
+		"title": `A & "B"`,
+		"image": "cat.png",
+		"count": "2",
+	}
+	if !reflect.DeepEqual(arguments, expected) {
+		t.Fatalf("Received:\n%+v\nExpected:\n%+v", arguments, expected)
+	}
+
+	for _, text := range []string{"", "card title", `card title="unclosed`} {
+		if _, _, err := parsePartialArguments(text); err == nil {
+			t.Fatalf("Expected error for %v but got nil", text)
+		}
+	}
+}
+
+func TestDedentMarkdown(t *testing.T) {
+	result := dedentMarkdown("# Title\n    \n    Some text\n      * Indented\n")
+	expected := "# Title\n\nSome text\n  * Indented\n"
+	if result != expected {
+		t.Fatalf("Result:\n%q\nExpected:\n%q", result, expected)
+	}
+
+	result = dedentMarkdown("Text\n\n    code")
+	if result != "Text\n\ncode" {
+		t.Fatalf("Result:\n%q\nExpected:\n%q", result, "Text\n\ncode")
+	}
+}
+
+func TestMustachePartialWithArguments(t *testing.T) {
+	engine := mustacheEngine{
+		partials: map[string]string{
+			"components/card": `<div class="card"><h2>{{ title }}</h2><img src="{{ image }}">{{ site }}</div>`,
+			"notes":           "Hello _{{ name }}_",
+		},
+		partialPaths: map[string]string{
+			"components/card": "/site/partials/components/card.html",
+			"notes":           "/site/partials/notes.markdown",
+		},
+		markdown: newTestMarkdown(t, files.MarkdownConfig{}),
+	}
+	context := map[string]interface{}{
+		"site":  "Example",
+		"name":  "**World**",
+		"posts": []map[string]string{{"title": "One & Two"}},
+	}
+
+	result, err := engine.Render(`{{#posts}}{{#partial}}components/card title="{{ title }}" image=cat.png{{/partial}}{{/posts}}{{> notes }}`, "test.html", nil, context)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := `<div class="card"><h2>One &amp; Two</h2><img src="cat.png">Example</div><p>Hello <em><strong>World</strong></em></p>
+`
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+
+	engine.strict = true
+	_, err = engine.Render(`{{#partial}}nope{{/partial}}`, "test.html", nil, context)
+	if err == nil {
+		t.Fatalf("Expected error but got nil")
+	}
+}
+
+func TestGoPartialWithArguments(t *testing.T) {
+	engine := goEngine{
+		partials: map[string]string{
+			"components/card": `<h2>{{ .title }}</h2>`,
+			"notes":           "Hello _{{ .name }}_",
+		},
+		partialPaths: map[string]string{
+			"notes": "/site/partials/notes.md",
+		},
+		markdown: newTestMarkdown(t, files.MarkdownConfig{}),
+	}
+	context := map[string]interface{}{"name": "<World>"}
+
+	result, err := engine.Render(`{{ partial "components/card" (dict "title" "A & B") }}{{ partial "notes" }}`, "test.html", nil, context)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := "<h2>A &amp; B</h2><p>Hello <em>&lt;World&gt;</em></p>\n"
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+}
+
+func TestLoadPartialsInSubdirectories(t *testing.T) {
+	test_files := map[string]string{
+		"components/card.html":     "<div>{{ title }}</div>",
+		"components/list/index.md": "* {{ item }}",
+		"footer.markdown":          "_{{ site.title }}_",
+	}
+	temporaryDirectory := writeTestSite(t, test_files)
+
+	partials, partialPaths, err := loadPartials([]string{temporaryDirectory})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"components/card": "<div>{{ title }}</div>",
+		"components/list": "* {{ item }}",
+		"footer":          "_{{ site.title }}_",
+	}
+	if !reflect.DeepEqual(partials, expected) {
+		t.Fatalf("Received:\n%+v\nExpected:\n%+v", partials, expected)
+	}
+
+	if partialPaths["components/card"] != path.Join(temporaryDirectory, "components/card.html") {
+		t.Fatalf("Incorrect partialPaths[\"components/card\"]: %v", partialPaths["components/card"])
+	}
+	if !markdownPartial(partialPaths, "footer") || markdownPartial(partialPaths, "components/card") {
+		t.Fatalf("Incorrect markdown partials: %v", partialPaths)
+	}
+}
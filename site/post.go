@@ -4,42 +4,41 @@ import (
 	"log"
 	"time"
 
-	"github.com/cbroglie/mustache"
 	"github.com/michaelenger/brage/files"
 )
 
 // A blog post.
 type Post struct {
 	Path        string
+	Source      string
 	Title       string
 	Description string
 	Image       string
 	Date        time.Time
 	Template    string
+	Headings    []files.Heading
+	Backlinks   []Link
+	Resources   []Resource
+	Collection  string
+	Authors     []string
+	Metadata    map[string]interface{}
 }
 
-// Type alias for sorting posts by date.
-type byPostDate []Post
+// Make a post out the given File, rendering markdown with the given renderer.
+func MakePost(file files.File, pathName string, markdown *files.Markdown) Post {
+	post, content := readPost(file, pathName)
+	post.renderContent(markdown, file.Type, content, nil)
 
-func (s byPostDate) Len() int {
-	return len(s)
-}
-func (s byPostDate) Swap(i, j int) {
-	s[i], s[j] = s[j], s[i]
-}
-func (s byPostDate) Less(i, j int) bool {
-	return s[i].Date.Before(s[j].Date)
+	return post
 }
 
-// Make a post out the given File.
-func MakePost(file files.File, pathName string) Post {
-	var content string
+// Make a post out of the given File without rendering it, returning the
+// content which is left once the front matter has been removed.
+func readPost(file files.File, pathName string) (Post, []byte) {
 	var metadata map[string]interface{}
-	switch file.Type {
-	case files.MarkdownFile:
-		metadata, content = files.ParseMarkdown(file.Content)
-	default:
-		content = string(file.Content)
+	content := file.Content
+	if file.Type == files.MarkdownFile {
+		metadata, content = files.ParseFrontMatter(file.Content)
 	}
 
 	title := files.PathToTitle(file.Path)
@@ -72,46 +71,84 @@ func MakePost(file files.File, pathName string) Post {
 	}
 
 	return Post{
-		pathName,
-		title,
-		description,
-		image,
-		publishedDate,
-		content,
+		Path:        pathName,
+		Source:      file.Path,
+		Title:       title,
+		Description: description,
+		Image:       image,
+		Date:        publishedDate,
+		Authors:     authorIds(metadata),
+		Metadata:    metadata,
+	}, content
+}
+
+// Render the content of a post into its template, rewriting the links in
+// markdown using the given function (if any).
+func (post *Post) renderContent(markdown *files.Markdown, fileType files.FileType, content []byte, links files.LinkFunc) {
+	if fileType != files.MarkdownFile {
+		post.Template = string(content)
+		return
 	}
+
+	post.Template, post.Headings = markdown.RenderWithLinks(content, links)
 }
 
 // Create the context used when rendering the post.
 func (post Post) makeContext(site Site) map[string]interface{} {
+	authors := make([]map[string]interface{}, len(post.Authors))
+	for i, id := range post.Authors {
+		authors[i] = site.makeAuthorContext(id)
+	}
+
 	postContext := map[string]interface{}{
-		"path":        post.Path,
-		"template":    post.Template,
-		"title":       post.Title,
-		"description": post.Description,
-		"date":        post.Date.Format("2006-01-02"),
+		"path":          post.Path,
+		"template":      post.Template,
+		"title":         post.Title,
+		"description":   post.Description,
+		"image":         post.Image,
+		"date":          post.Date.Format("2006-01-02"),
+		"collection":    post.Collection,
+		"authors":       authors,
+		"toc":           makeTocContext(post.Headings, site.Config.Toc),
+		"backlinks":     makeBacklinksContext(post.Backlinks),
+		"has_backlinks": len(post.Backlinks) > 0,
 	}
+	site.addImageContext(postContext, post.Image)
+
+	siteContext := site.MakeContext()
+	siteContext["menus"] = site.makeMenusContext(post.Path)
 
 	return map[string]interface{}{
-		"site": site.MakeContext(),
+		"site": siteContext,
 		"post": postContext,
 		"data": site.Config.Data,
 	}
 }
 
+// Get the layout used when rendering the post, based on its front matter or
+// its collection.
+func (post Post) layout(site Site) string {
+	if val, ok := post.Metadata["layout"].(string); ok {
+		return val
+	}
+
+	if collection, ok := site.Collections[post.Collection]; ok {
+		return collection.Layout
+	}
+
+	return PostLayout
+}
+
 // Render a post using a specific site config and layout file.
 func (post Post) Render(site Site) (string, error) {
 	context := post.makeContext(site)
 
-	partialsProvider := &mustache.StaticProvider{site.Partials}
-
-	return mustache.RenderInLayoutPartials(post.Template, site.Layouts[PostLayout], partialsProvider, context)
+	return site.renderInLayout(post.Template, post.Source, post.layout(site), context)
 }
 
 // Render a post using a specific site config but without the layout file.
 func (post Post) RenderTemplate(site Site) (string, error) {
 	context := post.makeContext(site)
 
-	partialsProvider := &mustache.StaticProvider{site.Partials}
-
-	return mustache.RenderInLayoutPartials(post.Template, "{{{ content }}}", partialsProvider, context)
+	return site.renderInLayout(post.Template, post.Source, "", context)
 }
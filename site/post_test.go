@@ -12,9 +12,9 @@ import (
 
 func TestMakePost(t *testing.T) {
 	file := files.File{
-		files.MarkdownFile,
-		"/tmp/test.md",
-		[]byte(`---
+		Type: files.MarkdownFile,
+		Path: "/tmp/test.md",
+		Content: []byte(`---
 title: Testing!
 description: I am described.
 image: foo.png
@@ -25,15 +25,22 @@ This is just a test.`),
 	}
 	expectedTime, _ := time.Parse("2006-01-02", "2020-10-01")
 	expected := Post{
-		"/blog/test",
-		"Testing!",
-		"I am described.",
-		"foo.png",
-		expectedTime,
-		"<p>This is just a test.</p>\n",
+		Path:        "/blog/test",
+		Source:      "/tmp/test.md",
+		Title:       "Testing!",
+		Description: "I am described.",
+		Image:       "foo.png",
+		Date:        expectedTime,
+		Template:    "<p>This is just a test.</p>\n",
+		Metadata: map[string]interface{}{
+			"title":       "Testing!",
+			"description": "I am described.",
+			"image":       "foo.png",
+			"date":        "2020-10-01",
+		},
 	}
 
-	result := MakePost(file, "/blog/test")
+	result := MakePost(file, "/blog/test", newTestMarkdown(t, files.MarkdownConfig{}))
 
 	if !reflect.DeepEqual(result, expected) {
 		t.Fatalf("Received:\n%+v\nExpected:\n%+v", result, expected)
@@ -42,9 +49,9 @@ This is just a test.`),
 
 func TestMakePostWithDateTime(t *testing.T) {
 	file := files.File{
-		files.MarkdownFile,
-		"/tmp/test.md",
-		[]byte(`---
+		Type: files.MarkdownFile,
+		Path: "/tmp/test.md",
+		Content: []byte(`---
 title: Testing!
 description: I am described.
 image: foo.png
@@ -55,15 +62,22 @@ This is just a test.`),
 	}
 	expectedTime, _ := time.Parse(time.DateTime, "2020-10-01 12:13:14")
 	expected := Post{
-		"/blog/test",
-		"Testing!",
-		"I am described.",
-		"foo.png",
-		expectedTime,
-		"<p>This is just a test.</p>\n",
+		Path:        "/blog/test",
+		Source:      "/tmp/test.md",
+		Title:       "Testing!",
+		Description: "I am described.",
+		Image:       "foo.png",
+		Date:        expectedTime,
+		Template:    "<p>This is just a test.</p>\n",
+		Metadata: map[string]interface{}{
+			"title":       "Testing!",
+			"description": "I am described.",
+			"image":       "foo.png",
+			"date":        "2020-10-01 12:13:14",
+		},
 	}
 
-	result := MakePost(file, "/blog/test")
+	result := MakePost(file, "/blog/test", newTestMarkdown(t, files.MarkdownConfig{}))
 
 	if !reflect.DeepEqual(result, expected) {
 		t.Fatalf("Received:\n%+v\nExpected:\n%+v", result, expected)
@@ -72,20 +86,21 @@ This is just a test.`),
 
 func TestMakePostDefaultMetadata(t *testing.T) {
 	file := files.File{
-		files.MarkdownFile,
-		"/tmp/some-test.md",
-		[]byte("This is a test"),
+		Type:    files.MarkdownFile,
+		Path:    "/tmp/some-test.md",
+		Content: []byte("This is a test"),
 	}
 	expected := Post{
-		"/blog/some-test",
-		"Some Test",
-		"",
-		"",
-		time.Now(),
-		"<p>This is a test</p>\n",
+		Path:        "/blog/some-test",
+		Source:      "/tmp/some-test.md",
+		Title:       "Some Test",
+		Description: "",
+		Image:       "",
+		Date:        time.Now(),
+		Template:    "<p>This is a test</p>\n",
 	}
 
-	result := MakePost(file, "/blog/some-test")
+	result := MakePost(file, "/blog/some-test", newTestMarkdown(t, files.MarkdownConfig{}))
 
 	result.Date = expected.Date // we'll be milliseconds off, so no point in checking
 
@@ -96,20 +111,21 @@ func TestMakePostDefaultMetadata(t *testing.T) {
 
 func TestMakePostHtmlFile(t *testing.T) {
 	file := files.File{
-		files.HtmlFile,
-		"/tmp/another-test.html",
-		[]byte("This is a test"),
+		Type:    files.HtmlFile,
+		Path:    "/tmp/another-test.html",
+		Content: []byte("This is a test"),
 	}
 	expected := Post{
-		"/another-test",
-		"Another Test",
-		"",
-		"",
-		time.Now(),
-		"This is a test",
+		Path:        "/another-test",
+		Source:      "/tmp/another-test.html",
+		Title:       "Another Test",
+		Description: "",
+		Image:       "",
+		Date:        time.Now(),
+		Template:    "This is a test",
 	}
 
-	result := MakePost(file, "/another-test")
+	result := MakePost(file, "/another-test", newTestMarkdown(t, files.MarkdownConfig{}))
 
 	result.Date = expected.Date // we'll be milliseconds off, so no point in checking
 
@@ -129,12 +145,12 @@ func TestPostRender(t *testing.T) {
 	date, _ := time.Parse(time.DateOnly, "2010-09-08")
 
 	post := Post{
-		"/example",
-		"This is a post",
-		"Just a description.",
-		"",
-		date,
-		`<h1>{{ post.title }}</h1>
+		Path:        "/example",
+		Title:       "This is a post",
+		Description: "Just a description.",
+		Image:       "",
+		Date:        date,
+		Template: `<h1>{{ post.title }}</h1>
 		<h2>{{ post.date }}</h2>
 		{{ #data.skills }}
 			<p>{{ . }}</p>
@@ -161,21 +177,23 @@ func TestPostRender(t *testing.T) {
 		</body>`
 
 	site := Site{
-		testConfig,
-		temporaryDirectory,
-		map[LayoutType]string{
-			PostLayout: `<head>
-				<title>{{ site.title }}</title>
-			</head>
-			<body>
-				{{{ content }}}
-			</body>`,
+		Config:          testConfig,
+		SourceDirectory: temporaryDirectory,
+		Layouts: map[string]Layout{
+			PostLayout: {
+				Template: `<head>
+					<title>{{ site.title }}</title>
+				</head>
+				<body>
+					{{{ content }}}
+				</body>`,
+			},
 		},
-		[]Page{},
-		map[string]string{
+		Pages: []Page{},
+		Partials: map[string]string{
 			"temp": `<em>This is from a template</em>`,
 		},
-		[]Post{},
+		Posts: []Post{},
 	}
 
 	result, err := post.Render(site)
@@ -202,12 +220,12 @@ func TestPostRenderTemplate(t *testing.T) {
 	date, _ := time.Parse(time.DateOnly, "2010-09-08")
 
 	post := Post{
-		"/example",
-		"This is a post",
-		"Just a description.",
-		"",
-		date,
-		`<h1>{{ post.title }}</h1>
+		Path:        "/example",
+		Title:       "This is a post",
+		Description: "Just a description.",
+		Image:       "",
+		Date:        date,
+		Template: `<h1>{{ post.title }}</h1>
 		<h2>{{ post.date }}</h2>
 		{{ #data.skills }}
 			<p>{{ . }}</p>
@@ -228,21 +246,23 @@ func TestPostRenderTemplate(t *testing.T) {
 			<em>This is from a template</em>`
 
 	site := Site{
-		testConfig,
-		temporaryDirectory,
-		map[LayoutType]string{
-			PostLayout: `<head>
-				<title>{{ site.title }}</title>
-			</head>
-			<body>
-				{{{ content }}}
-			</body>`,
+		Config:          testConfig,
+		SourceDirectory: temporaryDirectory,
+		Layouts: map[string]Layout{
+			PostLayout: {
+				Template: `<head>
+					<title>{{ site.title }}</title>
+				</head>
+				<body>
+					{{{ content }}}
+				</body>`,
+			},
 		},
-		[]Page{},
-		map[string]string{
+		Pages: []Page{},
+		Partials: map[string]string{
 			"temp": `<em>This is from a template</em>`,
 		},
-		[]Post{},
+		Posts: []Post{},
 	}
 
 	result, err := post.RenderTemplate(site)
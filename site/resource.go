@@ -0,0 +1,85 @@
+package site
+
+import (
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/michaelenger/brage/files"
+)
+
+// A file in the directory of a post which is copied alongside it, along with
+// the path it's available at when the site is built.
+type Resource struct {
+	Source string
+	Path   string
+}
+
+// Make the resources of a post out of the files in its directory, keyed by
+// their path relative to the directory.
+func makeResources(postPath string, resources map[string]string) []Resource {
+	items := []Resource{}
+	for name, source := range resources {
+		items = append(items, Resource{Source: source, Path: path.Join(postPath, name)})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Path < items[j].Path
+	})
+
+	return items
+}
+
+// Get the path of a resource referenced relative to a post, or nothing if
+// the reference doesn't point to one of its resources.
+func resolveResource(reference string, postPath string, resources map[string]string) string {
+	if reference == "" || strings.HasPrefix(reference, "/") || strings.HasPrefix(reference, "#") || strings.Contains(reference, ":") {
+		return ""
+	}
+
+	name, suffix := reference, ""
+	if i := strings.IndexAny(reference, "?#"); i >= 0 {
+		name, suffix = reference[:i], reference[i:]
+	}
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+	name = path.Clean(name)
+
+	if _, ok := resources[name]; !ok {
+		return ""
+	}
+
+	return path.Join(postPath, name) + suffix
+}
+
+// Make the function rewriting the relative links in the markdown of a post
+// which point to its resources, so that they keep working regardless of the
+// URL of the post.
+func resourceLinks(postPath string, resources map[string]string) files.LinkFunc {
+	if len(resources) == 0 {
+		return nil
+	}
+
+	return func(destination string) string {
+		if resolved := resolveResource(destination, postPath, resources); resolved != "" {
+			return resolved
+		}
+		return destination
+	}
+}
+
+// Find the resource of a post which is available at the given path.
+func (site Site) FindResource(resourcePath string) (Resource, bool) {
+	for _, collection := range site.Collections {
+		for _, post := range collection.Entries {
+			for _, resource := range post.Resources {
+				if resource.Path == resourcePath {
+					return resource, true
+				}
+			}
+		}
+	}
+
+	return Resource{}, false
+}
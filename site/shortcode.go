@@ -0,0 +1,477 @@
+package site
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/michaelenger/brage/files"
+)
+
+// A shortcode used in markdown content, e.g. `{{< youtube id="abc" >}}`, or
+// wrapping some content, e.g. `{{< note >}}Content{{< /note >}}`.
+type Shortcode struct {
+	Name      string
+	Arguments map[string]string
+	Content   string
+}
+
+// A function which renders a built-in shortcode to HTML.
+type shortcodeFunc func(site Site, shortcode Shortcode) (string, error)
+
+// Pattern matching an escaped shortcode tag, e.g. `{{</* name */>}}`, or an
+// opening, closing or self-closing one.
+var shortcodePattern = regexp.MustCompile(`\{\{</\*(.*?)\*/>\}\}|\{\{<\s*(/?)\s*([\w-]+(?:/[\w-]+)*)((?:\s+[^>]*?)?)\s*(/?)\s*>\}\}`)
+
+// Pattern matching the line which opens or closes a fenced code block.
+var codeFencePattern = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+
+// Pattern matching the ID of an embedded video.
+var videoIdPattern = regexp.MustCompile(`^[\w-]+$`)
+
+// Extensions of the files included in a gallery.
+var galleryExtensions = map[string]bool{
+	".avif": true,
+	".gif":  true,
+	".jpeg": true,
+	".jpg":  true,
+	".png":  true,
+	".svg":  true,
+	".webp": true,
+}
+
+// The built-in shortcodes, which can be overridden by the site.
+var builtinShortcodes = map[string]shortcodeFunc{
+	"figure":  figureShortcode,
+	"youtube": youtubeShortcode,
+	"vimeo":   vimeoShortcode,
+	"gallery": galleryShortcode,
+	"include": includeShortcode,
+	"note":    calloutShortcode,
+	"warning": calloutShortcode,
+}
+
+// Make an attribute for an HTML element, or nothing if the value is empty.
+func htmlAttribute(name string, value string) string {
+	if value == "" {
+		return ""
+	}
+
+	return fmt.Sprintf(` %v="%v"`, name, html.EscapeString(value))
+}
+
+// An image with an optional caption and link.
+func figureShortcode(site Site, shortcode Shortcode) (string, error) {
+	src := shortcode.Arguments["src"]
+	if src == "" {
+		return "", fmt.Errorf("Missing src")
+	}
+
+	image := fmt.Sprintf(`<img%v%v%v loading="lazy">`, htmlAttribute("src", site.imageSource(src)), htmlAttribute("alt", shortcode.Arguments["alt"]), site.imageAttributesHTML(src))
+	if link := shortcode.Arguments["link"]; link != "" {
+		image = fmt.Sprintf(`<a%v>%v</a>`, htmlAttribute("href", link), image)
+	}
+
+	caption := ""
+	if val := shortcode.Arguments["caption"]; val != "" {
+		caption = fmt.Sprintf("<figcaption>%v</figcaption>", html.EscapeString(val))
+	}
+
+	return fmt.Sprintf("<figure%v>%v%v</figure>", htmlAttribute("class", shortcode.Arguments["class"]), image, caption), nil
+}
+
+// Make a video embed using the given URL.
+func videoEmbed(shortcode Shortcode, embedUrl string) (string, error) {
+	id := shortcode.Arguments["id"]
+	if !videoIdPattern.MatchString(id) {
+		return "", fmt.Errorf("Invalid video id: %v", id)
+	}
+
+	title := shortcode.Arguments["title"]
+	if title == "" {
+		title = "Video"
+	}
+
+	return fmt.Sprintf(`<div class="video"><iframe%v%v loading="lazy" referrerpolicy="strict-origin-when-cross-origin" allow="fullscreen; picture-in-picture" allowfullscreen></iframe></div>`,
+		htmlAttribute("src", fmt.Sprintf(embedUrl, id)),
+		htmlAttribute("title", title),
+	), nil
+}
+
+// A YouTube video, embedded without tracking cookies.
+func youtubeShortcode(site Site, shortcode Shortcode) (string, error) {
+	return videoEmbed(shortcode, "https://www.youtube-nocookie.com/embed/%v")
+}
+
+// A Vimeo video, embedded without tracking.
+func vimeoShortcode(site Site, shortcode Shortcode) (string, error) {
+	return videoEmbed(shortcode, "https://player.vimeo.com/video/%v?dnt=1")
+}
+
+// A gallery of all the images in a directory in the assets.
+func galleryShortcode(site Site, shortcode Shortcode) (string, error) {
+	directory := path.Clean("/" + shortcode.Arguments["dir"])
+
+	names := map[string]bool{}
+	for _, siteDirectory := range site.Directories() {
+		entries, err := os.ReadDir(path.Join(siteDirectory, "assets", directory))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && galleryExtensions[strings.ToLower(path.Ext(entry.Name()))] {
+				names[entry.Name()] = true
+			}
+		}
+	}
+	if len(names) == 0 {
+		return "", fmt.Errorf("No images found in assets directory: %v", directory)
+	}
+
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var builder strings.Builder
+	builder.WriteString(`<div class="gallery">`)
+	for _, name := range sorted {
+		imageUrl := path.Join("/assets", directory, name)
+		fmt.Fprintf(&builder, `<figure><a%v><img%v%v%v loading="lazy"></a></figure>`,
+			htmlAttribute("href", imageUrl),
+			htmlAttribute("src", site.imageSource(imageUrl)),
+			htmlAttribute("alt", files.PathToTitle(name)),
+			site.imageAttributesHTML(imageUrl),
+		)
+	}
+	builder.WriteString(`</div>`)
+
+	return builder.String(), nil
+}
+
+// Parse a range of lines in the form "3-10", "3-" or "3", returning the
+// first and last lines (0 if there is no limit).
+func parseLineRange(lines string) (int, int, error) {
+	if lines == "" {
+		return 1, 0, nil
+	}
+
+	from, to, isRange := strings.Cut(lines, "-")
+	first, err := strconv.Atoi(strings.TrimSpace(from))
+	if err != nil || first < 1 {
+		return 0, 0, fmt.Errorf("Invalid line range: %v", lines)
+	}
+	if !isRange {
+		return first, first, nil
+	}
+	if strings.TrimSpace(to) == "" {
+		return first, 0, nil
+	}
+
+	last, err := strconv.Atoi(strings.TrimSpace(to))
+	if err != nil || last < first {
+		return 0, 0, fmt.Errorf("Invalid line range: %v", lines)
+	}
+
+	return first, last, nil
+}
+
+// A snippet of a file in the site directory, optionally limited to a range
+// of lines.
+func includeShortcode(site Site, shortcode Shortcode) (string, error) {
+	file := shortcode.Arguments["file"]
+	if file == "" {
+		return "", fmt.Errorf("Missing file")
+	}
+
+	contents, err := os.ReadFile(path.Join(site.SourceDirectory, path.Clean("/"+file)))
+	if err != nil {
+		return "", fmt.Errorf("Unable to read file: %v", file)
+	}
+
+	first, last, err := parseLineRange(shortcode.Arguments["lines"])
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(contents), "\n"), "\n")
+	if last == 0 || last > len(lines) {
+		last = len(lines)
+	}
+	if first > last {
+		return "", fmt.Errorf("Line range is outside of the file: %v", shortcode.Arguments["lines"])
+	}
+	snippet := strings.Join(lines[first-1:last], "\n")
+
+	class := ""
+	if lang := shortcode.Arguments["lang"]; lang != "" {
+		class = htmlAttribute("class", "language-"+lang)
+	}
+
+	return fmt.Sprintf("<pre><code%v>%v\n</code></pre>", class, html.EscapeString(snippet)), nil
+}
+
+// A note or warning wrapping some content, with an optional title, rendered
+// the same way as the callouts in markdown.
+func calloutShortcode(site Site, shortcode Shortcode) (string, error) {
+	return site.renderCallout(files.Callout{
+		Kind:    shortcode.Name,
+		Title:   shortcode.Arguments["title"],
+		Content: shortcode.Content,
+	})
+}
+
+// Render the markdown in a shortcode, without wrapping it in a paragraph if
+// it's only a single line so that it can be used inline.
+func (site Site) renderShortcodeMarkdown(text string) string {
+	rendered := site.Markdown.Render([]byte(dedentMarkdown(text)))
+	if strings.Contains(text, "\n") {
+		return rendered
+	}
+
+	trimmed := strings.TrimSuffix(rendered, "\n")
+	if strings.HasPrefix(trimmed, "<p>") && strings.HasSuffix(trimmed, "</p>") && strings.Count(trimmed, "<p>") == 1 {
+		return trimmed[3 : len(trimmed)-4]
+	}
+
+	return rendered
+}
+
+// Render a shortcode using either the template in the site's shortcodes
+// directory or the built-in shortcode.
+func (site Site) renderShortcode(shortcode Shortcode) (string, error) {
+	if shortcodeTemplate, ok := site.Shortcodes[shortcode.Name]; ok {
+		context := map[string]interface{}{
+			"content": template.HTML(shortcode.Content),
+			"data":    site.Config.Data,
+		}
+		for key, value := range shortcode.Arguments {
+			context[key] = value
+		}
+
+		source := partialSource(site.ShortcodePaths, shortcode.Name)
+		rendered, err := site.engine().Render(shortcodeTemplate, source, nil, context)
+		if err != nil {
+			return "", err
+		}
+		if markdownPartial(site.ShortcodePaths, shortcode.Name) {
+			rendered = site.renderShortcodeMarkdown(rendered)
+		}
+
+		return rendered, nil
+	}
+
+	if render, ok := builtinShortcodes[shortcode.Name]; ok {
+		return render(site, shortcode)
+	}
+
+	return "", fmt.Errorf("Unknown shortcode: %v", shortcode.Name)
+}
+
+// A shortcode tag found in some markdown, along with the tags it wraps and
+// where its closing tag is (if it has one).
+type shortcodeTag struct {
+	start     int
+	end       int
+	name      string
+	arguments string
+	escaped   string
+	closing   bool
+	wrapping  bool
+	children  []*shortcodeTag
+	closeFrom int
+	closeTo   int
+}
+
+// Find the parts of some markdown which are in fenced code blocks, as pairs
+// of start and end offsets.
+func codeBlockRanges(text string) [][2]int {
+	ranges := [][2]int{}
+	fence := ""
+	start := 0
+
+	for offset := 0; offset < len(text); {
+		lineEnd := strings.IndexByte(text[offset:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(text)
+		} else {
+			lineEnd += offset + 1
+		}
+		line := strings.TrimRight(text[offset:lineEnd], "\r\n")
+
+		if match := codeFencePattern.FindStringSubmatch(line); match != nil {
+			if fence == "" {
+				fence = match[1]
+				start = offset
+			} else if match[1][0] == fence[0] && len(match[1]) >= len(fence) && strings.TrimSpace(strings.TrimLeft(line, " "+fence[:1])) == "" {
+				ranges = append(ranges, [2]int{start, lineEnd})
+				fence = ""
+			}
+		}
+
+		offset = lineEnd
+	}
+	if fence != "" {
+		ranges = append(ranges, [2]int{start, len(text)})
+	}
+
+	return ranges
+}
+
+// Find the shortcode tags in some markdown, outside of the front matter and
+// code blocks, and pair the opening tags with their closing ones.
+func parseShortcodeTags(text string) ([]*shortcodeTag, error) {
+	_, _, line := files.ParseFrontMatterWithLine([]byte(text))
+	bodyStart := 0
+	for ; line > 1; line-- {
+		bodyStart += strings.IndexByte(text[bodyStart:], '\n') + 1
+	}
+	body := text[bodyStart:]
+	codeBlocks := codeBlockRanges(body)
+
+	root := &shortcodeTag{}
+	stack := []*shortcodeTag{root}
+
+	for _, match := range shortcodePattern.FindAllStringSubmatchIndex(body, -1) {
+		inCode := false
+		for _, block := range codeBlocks {
+			if match[0] >= block[0] && match[0] < block[1] {
+				inCode = true
+				break
+			}
+		}
+		if inCode {
+			continue
+		}
+
+		tag := &shortcodeTag{start: bodyStart + match[0], end: bodyStart + match[1]}
+		parent := stack[len(stack)-1]
+
+		switch {
+		case match[2] >= 0:
+			tag.escaped = "{{<" + body[match[2]:match[3]] + ">}}"
+			parent.children = append(parent.children, tag)
+		case match[5] > match[4]:
+			name := body[match[6]:match[7]]
+			if parent == root || parent.name != name {
+				return nil, fmt.Errorf("Closing shortcode without an opening one: %v", name)
+			}
+			parent.closeFrom, parent.closeTo = tag.start, tag.end
+			stack = stack[:len(stack)-1]
+		default:
+			tag.name = body[match[6]:match[7]]
+			tag.arguments = body[match[8]:match[9]]
+			parent.children = append(parent.children, tag)
+			if match[11] == match[10] {
+				tag.wrapping = true
+				stack = append(stack, tag)
+			}
+		}
+	}
+
+	if len(stack) > 1 {
+		name := stack[len(stack)-1].name
+		return nil, fmt.Errorf("Shortcode without a closing tag: %v (self-closing shortcodes are written as {{< %v />}})", name, name)
+	}
+
+	return root.children, nil
+}
+
+// Replace the given shortcode tags in a part of some markdown with
+// placeholders, returning the rendered shortcodes.
+func (site Site) renderShortcodeTags(text string, start int, end int, tags []*shortcodeTag) (string, []string, error) {
+	var builder strings.Builder
+	rendered := []string{}
+
+	for _, tag := range tags {
+		builder.WriteString(text[start:tag.start])
+
+		if tag.escaped != "" {
+			builder.WriteString(tag.escaped)
+			start = tag.end
+			continue
+		}
+
+		arguments, err := parseArguments(tag.arguments)
+		if err != nil {
+			return "", nil, fmt.Errorf("Unable to parse shortcode %v: %v", tag.name, err)
+		}
+		shortcode := Shortcode{Name: tag.name, Arguments: arguments}
+		start = tag.end
+
+		// Shortcodes with a closing tag wrap their content, which is rendered
+		// as markdown
+		if tag.wrapping {
+			content, contentShortcodes, err := site.renderShortcodeTags(text, tag.end, tag.closeFrom, tag.children)
+			if err != nil {
+				return "", nil, err
+			}
+			shortcode.Content = restoreShortcodes(site.renderShortcodeMarkdown(content), contentShortcodes)
+			start = tag.closeTo
+		}
+
+		output, err := site.renderShortcode(shortcode)
+		if err != nil {
+			return "", nil, fmt.Errorf("Unable to render shortcode %v: %v", tag.name, err)
+		}
+
+		builder.WriteString(shortcodePlaceholder(len(rendered)))
+		rendered = append(rendered, output)
+	}
+	builder.WriteString(text[start:end])
+
+	return builder.String(), rendered, nil
+}
+
+// Replace the shortcodes in some markdown with placeholders, returning the
+// rendered shortcodes which replace the placeholders once the markdown has
+// been converted to HTML.
+func (site Site) expandShortcodes(text string) (string, []string, error) {
+	tags, err := parseShortcodeTags(text)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return site.renderShortcodeTags(text, 0, len(text), tags)
+}
+
+// Get the placeholder for a shortcode, which is left as is by markdown.
+func shortcodePlaceholder(index int) string {
+	return fmt.Sprintf("BRAGESHORTCODE%dEND", index)
+}
+
+// Replace the placeholders in HTML with the rendered shortcodes.
+func restoreShortcodes(content string, rendered []string) string {
+	for i := len(rendered) - 1; i >= 0; i-- {
+		placeholder := shortcodePlaceholder(i)
+		content = strings.ReplaceAll(content, "<p>"+placeholder+"</p>", rendered[i])
+		content = strings.ReplaceAll(content, placeholder, rendered[i])
+	}
+
+	return content
+}
+
+// Expand the shortcodes in a markdown file, returning the file with
+// placeholders and a function which replaces them in the rendered content.
+func (site Site) prepareContent(file files.File) (files.File, func(string) string, error) {
+	if file.Type != files.MarkdownFile || !shortcodePattern.Match(file.Content) {
+		return file, func(content string) string { return content }, nil
+	}
+
+	text, rendered, err := site.expandShortcodes(string(file.Content))
+	if err != nil {
+		return file, nil, fmt.Errorf("%v: %v", file.Path, err)
+	}
+	file.Content = []byte(text)
+
+	return file, func(content string) string {
+		return restoreShortcodes(content, rendered)
+	}, nil
+}
@@ -0,0 +1,239 @@
+package site
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/michaelenger/brage/files"
+)
+
+func createShortcodeSite(t *testing.T) string {
+	return writeTestSite(t, map[string]string{
+		"assets/photos/b.jpg":     "",
+		"assets/photos/a.png":     "",
+		"assets/photos/notes.txt": "",
+		"code/example.go":         "package main\n\nfunc main() {\n\tprintln(\"<hi>\")\n}\n",
+		"shortcodes/button.html":  `<a class="button" href="{{ href }}">{{{ content }}}</a>`,
+		"shortcodes/note.html":    `<aside>{{{ content }}}</aside>`,
+		"shortcodes/signature.md": "_{{ name }}_",
+		"pages/index.md":          "# Hello\n\n{{< figure src=\"/cat.png\" caption=\"A cat\" />}}\n",
+		"posts/video.md":          "---\ntitle: Video\n---\n{{< youtube id=\"abc123\" />}}\n",
+		"config.yaml":             "title: Shortcodes\n",
+	})
+}
+
+func TestBuiltinShortcodes(t *testing.T) {
+	dirPath := createShortcodeSite(t)
+
+	site := Site{SourceDirectory: dirPath, Markdown: newTestMarkdown(t, files.MarkdownConfig{})}
+
+	tests := []struct {
+		shortcode Shortcode
+		expected  string
+	}{
+		{
+			Shortcode{Name: "figure", Arguments: map[string]string{"src": "/cat.png", "alt": "Cat", "caption": "A <cat>", "link": "/cats"}},
+			`<figure><a href="/cats"><img src="/cat.png" alt="Cat" loading="lazy"></a><figcaption>A &lt;cat&gt;</figcaption></figure>`,
+		},
+		{
+			Shortcode{Name: "youtube", Arguments: map[string]string{"id": "abc123"}},
+			`<div class="video"><iframe src="https://www.youtube-nocookie.com/embed/abc123" title="Video" loading="lazy" referrerpolicy="strict-origin-when-cross-origin" allow="fullscreen; picture-in-picture" allowfullscreen></iframe></div>`,
+		},
+		{
+			Shortcode{Name: "vimeo", Arguments: map[string]string{"id": "42", "title": "Talk"}},
+			`<div class="video"><iframe src="https://player.vimeo.com/video/42?dnt=1" title="Talk" loading="lazy" referrerpolicy="strict-origin-when-cross-origin" allow="fullscreen; picture-in-picture" allowfullscreen></iframe></div>`,
+		},
+		{
+			Shortcode{Name: "gallery", Arguments: map[string]string{"dir": "photos"}},
+			`<div class="gallery"><figure><a href="/assets/photos/a.png"><img src="/assets/photos/a.png" alt="A" loading="lazy"></a></figure><figure><a href="/assets/photos/b.jpg"><img src="/assets/photos/b.jpg" alt="B" loading="lazy"></a></figure></div>`,
+		},
+		{
+			Shortcode{Name: "include", Arguments: map[string]string{"file": "code/example.go", "lines": "3-5", "lang": "go"}},
+			"<pre><code class=\"language-go\">func main() {\n\tprintln(&#34;&lt;hi&gt;&#34;)\n}\n</code></pre>",
+		},
+		{
+			Shortcode{Name: "warning", Arguments: map[string]string{"title": "Careful"}, Content: "<p>Hot</p>"},
+			"<aside class=\"callout callout-warning\">\n<p class=\"callout-title\">Careful</p>\n<p>Hot</p></aside>\n",
+		},
+	}
+
+	for _, test := range tests {
+		result, err := site.renderShortcode(test.shortcode)
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v", test.shortcode.Name, err)
+		}
+		if result != test.expected {
+			t.Fatalf("Result:\n%v\nExpected:\n%v", result, test.expected)
+		}
+	}
+
+	invalid := []Shortcode{
+		{Name: "figure"},
+		{Name: "youtube", Arguments: map[string]string{"id": `"><script>`}},
+		{Name: "gallery", Arguments: map[string]string{"dir": "nope"}},
+		{Name: "include", Arguments: map[string]string{"file": "code/example.go", "lines": "9-3"}},
+		{Name: "include", Arguments: map[string]string{"file": "../../etc/passwd"}},
+		{Name: "nope"},
+	}
+	for _, shortcode := range invalid {
+		if _, err := site.renderShortcode(shortcode); err == nil {
+			t.Fatalf("Expected error for %+v but got nil", shortcode)
+		}
+	}
+}
+
+func TestParseLineRange(t *testing.T) {
+	tests := map[string][2]int{
+		"":     {1, 0},
+		"3":    {3, 3},
+		"3-":   {3, 0},
+		"3-10": {3, 10},
+	}
+
+	for lines, expected := range tests {
+		first, last, err := parseLineRange(lines)
+		if err != nil {
+			t.Fatalf("Unexpected error for %v: %v", lines, err)
+		}
+		if first != expected[0] || last != expected[1] {
+			t.Fatalf("Result for %v:\n%d-%d\nExpected:\n%d-%d", lines, first, last, expected[0], expected[1])
+		}
+	}
+
+	for _, lines := range []string{"a", "0", "5-2", "1-b"} {
+		if _, _, err := parseLineRange(lines); err == nil {
+			t.Fatalf("Expected error for %v but got nil", lines)
+		}
+	}
+}
+
+func TestPrepareContent(t *testing.T) {
+	dirPath := createShortcodeSite(t)
+
+	site, err := Load(dirPath, Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	file := files.File{
+		Type: files.MarkdownFile,
+		Path: path.Join(dirPath, "pages/test.md"),
+		Content: []byte(`Hello {{< button href="/go" >}}**Go**{{< /button >}} there.
+
+{{< note >}}
+  Some _notes_ with a {{< signature name="Bob" />}}
+{{< /note >}}
+`),
+	}
+
+	prepared, restoreShortcodes, err := site.prepareContent(file)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	result := restoreShortcodes(files.RenderMarkdown(prepared.Content))
+	expected := `<p>Hello <a class="button" href="/go"><strong>Go</strong></a> there.</p>
+<aside><p>Some <em>notes</em> with a <em>Bob</em></p>
+</aside>
+`
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+
+	_, _, err = site.prepareContent(files.File{Type: files.MarkdownFile, Content: []byte("{{< /note >}}")})
+	if err == nil {
+		t.Fatalf("Expected error but got nil")
+	}
+
+	// Shortcodes are only expanded in markdown files
+	htmlFile := files.File{Type: files.HtmlFile, Content: []byte(`{{< figure src="x" >}}`)}
+	prepared, _, err = site.prepareContent(htmlFile)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(prepared.Content) != string(htmlFile.Content) {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", string(prepared.Content), string(htmlFile.Content))
+	}
+}
+
+func TestExpandShortcodes(t *testing.T) {
+	dirPath := createShortcodeSite(t)
+
+	site, err := Load(dirPath, Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := map[string]string{
+		// Shortcodes with the same name are paired with the closest closing tag
+		"{{< note >}}A {{< note >}}B{{< /note >}} C{{< /note >}}": "<aside>A <aside>B</aside> C</aside>",
+		// Self-closing shortcodes don't look for a closing tag
+		"{{< signature name=\"A\" />}} {{< note >}}B{{< /note >}}": "<em>A</em> <aside>B</aside>",
+		// Escaped shortcodes are left as they are
+		"`{{</* note */>}}` and {{</* /note */>}}": "`{{< note >}}` and {{< /note >}}",
+		// Shortcodes in the front matter and code blocks are left as they are
+		"---\ntitle: \"{{< note />}}\"\n---\nHi":                                                  "---\ntitle: \"{{< note />}}\"\n---\nHi",
+		"```\n{{< note >}}\n```\n\n~~~~\n{{< /note >}}\n```\n~~~~\n{{< signature name=\"A\" />}}": "```\n{{< note >}}\n```\n\n~~~~\n{{< /note >}}\n```\n~~~~\n<em>A</em>",
+	}
+	for text, expected := range tests {
+		expanded, rendered, err := site.expandShortcodes(text)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", text, err)
+		}
+		if result := restoreShortcodes(expanded, rendered); result != expected {
+			t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+		}
+	}
+
+	invalid := map[string]string{
+		"{{< figure src=\"/cat.png\" >}}":                       "Shortcode without a closing tag: figure",
+		"{{< note >}}{{< signature name=\"A\" >}}{{< /note >}}": "Closing shortcode without an opening one: note",
+		"{{< /note >}}": "Closing shortcode without an opening one: note",
+		"{{< note >}}Not closed\n```\n{{< /note >}}\n```\n": "Shortcode without a closing tag: note",
+	}
+	for text, expected := range invalid {
+		_, _, err := site.expandShortcodes(text)
+		if err == nil || !strings.HasPrefix(err.Error(), expected) {
+			t.Fatalf("Result:\n%v\nExpected:\n%v", err, expected)
+		}
+	}
+}
+
+func TestLoadWithShortcodes(t *testing.T) {
+	dirPath := createShortcodeSite(t)
+
+	site, err := Load(dirPath, Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(site.Pages) != 1 || !strings.Contains(site.Pages[0].Template, `<figure><img src="/cat.png" loading="lazy"><figcaption>A cat</figcaption></figure>`) {
+		t.Fatalf("Incorrect site.Pages: %+v", site.Pages)
+	}
+	if len(site.Posts) != 1 || !strings.Contains(site.Posts[0].Template, `src="https://www.youtube-nocookie.com/embed/abc123"`) {
+		t.Fatalf("Incorrect site.Posts: %+v", site.Posts)
+	}
+	if strings.Contains(site.Posts[0].Template, "<p><div") {
+		t.Fatalf("Shortcode wrapped in paragraph: %v", site.Posts[0].Template)
+	}
+}
+
+func TestLoadWithShortcodesStrict(t *testing.T) {
+	dirPath := createShortcodeSite(t)
+
+	// The button shortcode uses a variable which isn't passed to it
+	if err := os.WriteFile(path.Join(dirPath, "pages/about.md"), []byte("{{< button >}}Go{{< /button >}}\n"), 0644); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	if _, err := Load(dirPath, Options{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	_, err := Load(dirPath, Options{Strict: true})
+	if err == nil || !strings.Contains(err.Error(), "href") {
+		t.Fatalf("Expected error for missing variable but got: %v", err)
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path"
-	"sort"
+	"strings"
 
 	"github.com/michaelenger/brage/files"
 	"gopkg.in/yaml.v2"
@@ -12,79 +12,112 @@ import (
 
 type DataMap map[interface{}]interface{}
 
-// A layout type.
-type LayoutType uint8
-
-const (
-	DefaultLayout LayoutType = iota
-	PageLayout
-	PostLayout
-)
-
 type SiteConfig struct {
-	Title       string
-	Description string
-	Image       string
-	Author      string
-	RootUrl     string `yaml:"root_url"`
-	Redirects   map[string]string
-	Data        DataMap
+	Title          string
+	Description    string
+	Image          string
+	Author         string
+	RootUrl        string `yaml:"root_url"`
+	Redirects      map[string]string
+	Data           DataMap
+	Collections    map[string]CollectionConfig
+	Authors        map[string]AuthorConfig
+	Menus          map[string][]MenuItem
+	DefaultLayouts map[string]string `yaml:"default_layouts"`
+	Theme          string
+	TemplateEngine string `yaml:"template_engine"`
+	Markdown       files.MarkdownConfig
+	Toc            TocConfig
+	Fingerprint    bool `yaml:"fingerprint_assets"`
+	Bundles        map[string][]string
+	Images         files.ImageConfig
 }
 
 type Site struct {
 	Config          SiteConfig
 	SourceDirectory string
-	Layouts         map[LayoutType]string
+	ThemeDirectory  string
+	Layouts         map[string]Layout
 	Pages           []Page
+	Assets          map[string]Asset
+	Images          map[string]Image
 	Partials        map[string]string
+	PartialPaths    map[string]string
+	Shortcodes      map[string]string
+	ShortcodePaths  map[string]string
 	Posts           []Post
+	Collections     map[string]Collection
+	Menus           map[string][]MenuItem
+	PageTree        *PageNode
+	LinkTargets     map[string]Link
+	Markdown        *files.Markdown
+	Strict          bool
 }
 
-// Load partials from the given directory.
-func loadPartials(dirPath string) (map[string]string, error) {
+// Load partials from the given directories, with partials in later
+// directories replacing those in earlier ones. The partials are kept as
+// templates to be rendered when used, and are returned along with the files
+// they were loaded from.
+func loadPartials(dirPaths []string) (map[string]string, map[string]string, error) {
 	partials := map[string]string{}
+	partialPaths := map[string]string{}
 
-	partialsFileInfo, err := os.Stat(dirPath)
-	if err != nil || !partialsFileInfo.IsDir() {
-		return partials, nil
-	}
+	for _, dirPath := range dirPaths {
+		partialsFileInfo, err := os.Stat(dirPath)
+		if err != nil || !partialsFileInfo.IsDir() {
+			continue
+		}
 
-	partialFiles, err := files.ReadFiles(dirPath, "")
-	if err != nil {
-		return partials, err
-	}
-	for name, file := range partialFiles {
-		if path.Base(name) == "index" {
-			name = path.Clean(name[:len(name)-5])
+		partialFiles, err := files.ReadFiles(dirPath, "")
+		if err != nil {
+			return partials, partialPaths, err
 		}
+		for name, file := range partialFiles {
+			if path.Base(name) == "index" {
+				name = path.Clean(name[:len(name)-5])
+			}
 
-		partials[name] = file.Render()
+			partials[name] = string(file.Content)
+			partialPaths[name] = file.Path
+		}
 	}
 
-	return partials, nil
+	return partials, partialPaths, nil
 }
 
-// Load posts from the given directory.
-func loadPosts(dirPath string) []Post {
-	posts := []Post{}
+// Load the collections defined in the config, as well as the built-in posts.
+func loadCollections(site Site) (map[string]Collection, error) {
+	collections := map[string]Collection{}
 
-	postsFileInfo, err := os.Stat(dirPath)
-	if err != nil || !postsFileInfo.IsDir() {
-		return posts
+	configs := map[string]CollectionConfig{PostsCollection: {}}
+	for name, collectionConfig := range site.Config.Collections {
+		configs[name] = collectionConfig
 	}
 
-	postFiles, err := files.ReadFiles(dirPath, "")
-	for name, file := range postFiles {
-		posts = append(posts, MakePost(file, fmt.Sprintf("/%s", name)))
+	for name, collectionConfig := range configs {
+		collection, err := loadCollection(site, name, collectionConfig)
+		if err != nil {
+			return collections, err
+		}
+		collections[name] = collection
 	}
 
-	return posts
+	return collections, nil
+}
+
+// Options for loading a site.
+type Options struct {
+	Strict bool
 }
 
 // Load the site config based on a specified path and build the site description.
-func Load(siteDirectory string) (Site, error) {
+func Load(siteDirectory string, options Options) (Site, error) {
 	var site Site
 
+	// Anything rendered when loading the site, such as shortcodes, needs to
+	// know whether it's strict
+	site.Strict = options.Strict
+
 	if _, err := os.Stat(siteDirectory); os.IsNotExist(err) {
 		return site, fmt.Errorf("No site directory found at specified path: %v", siteDirectory)
 	}
@@ -106,43 +139,111 @@ func Load(siteDirectory string) (Site, error) {
 		return site, err
 	}
 
-	// Layouts
+	site.SourceDirectory = siteDirectory
 
-	layoutPath := path.Join(siteDirectory, "layout.html")
-	if _, err := os.Stat(layoutPath); os.IsNotExist(err) {
-		site.Layouts = map[LayoutType]string{
-			DefaultLayout: "{{{ content }}}",
-			PageLayout:    "{{{ content }}}",
-			PostLayout:    "{{{ content }}}",
-		}
-	} else {
-		contents, err = os.ReadFile(layoutPath)
-		if err != nil {
-			return site, fmt.Errorf("Unable to load layout template at path: %v", layoutPath)
+	if err = validateEngineName(site.Config.TemplateEngine); err != nil {
+		return site, err
+	}
+
+	// Theme
+
+	if site.Config.Theme != "" {
+		site.ThemeDirectory = themeDirectory(siteDirectory, site.Config.Theme)
+		if _, err := os.Stat(site.ThemeDirectory); os.IsNotExist(err) {
+			return site, fmt.Errorf("No theme found at specified path: %v", site.ThemeDirectory)
 		}
-		site.Layouts = map[LayoutType]string{
-			DefaultLayout: string(contents),
-			PageLayout:    string(contents),
-			PostLayout:    string(contents),
+
+		// Use the theme config as the defaults for the site config
+		themeConfigPath := path.Join(site.ThemeDirectory, "config.yaml")
+		if _, err := os.Stat(themeConfigPath); !os.IsNotExist(err) {
+			themeContents, err := os.ReadFile(themeConfigPath)
+			if err != nil {
+				return site, err
+			}
+
+			theme := site.Config.Theme
+			site.Config = SiteConfig{}
+			if err = yaml.Unmarshal(themeContents, &site.Config); err != nil {
+				return site, err
+			}
+			if err = yaml.Unmarshal(contents, &site.Config); err != nil {
+				return site, err
+			}
+			site.Config.Theme = theme
 		}
 	}
 
-	layoutPath = path.Join(siteDirectory, "layout-page.html")
-	if _, err := os.Stat(layoutPath); !os.IsNotExist(err) {
-		contents, err = os.ReadFile(layoutPath)
-		if err != nil {
-			return site, fmt.Errorf("Unable to load layout template at path: %v", layoutPath)
-		}
-		site.Layouts[PageLayout] = string(contents)
+	// Data
+
+	data, err := loadDataDirectory(path.Join(siteDirectory, "data"))
+	if err != nil {
+		return site, err
 	}
+	if site.Config.Data == nil {
+		site.Config.Data = DataMap{}
+	}
+	normaliseData(site.Config.Data)
+	mergeData(site.Config.Data, data)
 
-	layoutPath = path.Join(siteDirectory, "layout-post.html")
-	if _, err := os.Stat(layoutPath); !os.IsNotExist(err) {
-		contents, err = os.ReadFile(layoutPath)
-		if err != nil {
-			return site, fmt.Errorf("Unable to load layout template at path: %v", layoutPath)
-		}
-		site.Layouts[PostLayout] = string(contents)
+	// Layouts
+
+	site.Layouts, err = loadLayouts(site.Directories(), contentTemplates[site.engineName()])
+	if err != nil {
+		return site, err
+	}
+
+	// Assets
+
+	site.Assets, err = loadAssets(site.Directories(), site.Config.Bundles, site.Config.Fingerprint)
+	if err != nil {
+		return site, err
+	}
+
+	// Images
+
+	site.Images, err = loadImages(site.Assets, site.Config.Images)
+	if err != nil {
+		return site, err
+	}
+	if err = site.addGeneratedAssets(); err != nil {
+		return site, err
+	}
+
+	// Partials
+
+	partialsDirectories := []string{}
+	shortcodesDirectories := []string{}
+	for _, directory := range site.Directories() {
+		partialsDirectories = append(partialsDirectories, path.Join(directory, "partials"))
+		shortcodesDirectories = append(shortcodesDirectories, path.Join(directory, "shortcodes"))
+	}
+	site.Partials, site.PartialPaths, err = loadPartials(partialsDirectories)
+	if err != nil {
+		return site, err
+	}
+
+	// Shortcodes
+
+	site.Shortcodes, site.ShortcodePaths, err = loadPartials(shortcodesDirectories)
+	if err != nil {
+		return site, err
+	}
+
+	// Markdown
+
+	// Callouts are rendered with the markdown renderer of the site, so they
+	// need to use the site once it has one
+	site.Config.Markdown.Toc = site.Config.Toc.configured()
+	site.Config.Markdown.RootUrl = site.Config.RootUrl
+	site.Config.Markdown.Callout = func(callout files.Callout) (string, error) {
+		return site.renderCallout(callout)
+	}
+	if len(site.Images) > 0 {
+		site.Config.Markdown.Images = site.imageAttributes
+	}
+	site.Markdown, err = files.NewMarkdown(site.Config.Markdown)
+	if err != nil {
+		return site, err
 	}
 
 	// Pages
@@ -157,44 +258,78 @@ func Load(siteDirectory string) (Site, error) {
 		return site, err
 	}
 
-	site.SourceDirectory = siteDirectory
 	for name, file := range pageFiles {
 		if path.Base(name) == "index" {
 			name = path.Clean(name[:len(name)-5])
 		}
 
-		template := file.Render()
+		file, restoreShortcodes, err := site.prepareContent(file)
+		if err != nil {
+			return site, err
+		}
+
+		pages, err := makePages(siteDirectory, name, file, site.Markdown)
+		if err != nil {
+			return site, err
+		}
+		for i := range pages {
+			pages[i].Template = restoreShortcodes(pages[i].Template)
+		}
+		site.Pages = append(site.Pages, pages...)
+	}
+
+	// Collections
 
-		site.Pages = append(site.Pages, Page{name, template})
+	site.Collections, err = loadCollections(site)
+	if err != nil {
+		return site, err
 	}
+	site.Posts = site.Collections[PostsCollection].Entries
 
-	// Partials
+	// Authors
+
+	// Unknown authors are only logged, unless the site is strict
+	if unknown := site.checkAuthors(); site.Strict && len(unknown) > 0 {
+		return site, fmt.Errorf("Unknown authors: %v", strings.Join(unknown, ", "))
+	}
 
-	site.Partials, err = loadPartials(path.Join(siteDirectory, "partials"))
+	authorPages, err := makeAuthorPages(site)
 	if err != nil {
 		return site, err
 	}
+	site.Pages = append(site.Pages, authorPages...)
 
-	// Posts
+	// Wiki links
 
-	site.Posts = loadPosts(path.Join(siteDirectory, "posts"))
-	sort.Sort(sort.Reverse(byPostDate(site.Posts)))
+	if site.Config.Markdown.WikiLinks {
+		site.LinkTargets = makeLinkTargets(site)
+		site.resolveWikiLinks()
+	}
+
+	// Menus
+
+	site.Menus = collectMenus(site.Config, site.Pages)
+	if err = validateMenus(site.Menus); err != nil {
+		return site, err
+	}
+
+	// Page tree
+
+	site.PageTree = buildPageTree(site.Pages)
 
 	return site, nil
 }
 
 // Make the site context used when rendering pages and posts.
 func (site Site) MakeContext() map[string]interface{} {
-	posts := make([]map[string]string, len(site.Posts))
-	for i := range site.Posts {
-		posts[i] = map[string]string{
-			"path":  site.Posts[i].Path,
-			"title": site.Posts[i].Title,
-			"date":  site.Posts[i].Date.Format("2006-01-02"),
-		}
+	posts := Collection{Entries: site.Posts}.makeContext()
+
+	collections := map[string]interface{}{}
+	for name, collection := range site.Collections {
+		collections[name] = collection.makeContext()
 	}
 
-	return map[string]interface{}{
+	context := map[string]interface{}{
 		"title":       site.Config.Title,
 		"description": site.Config.Description,
 		"image":       site.Config.Image,
@@ -202,5 +337,11 @@ func (site Site) MakeContext() map[string]interface{} {
 		"root_url":    site.Config.RootUrl,
 		"redirects":   site.Config.Redirects,
 		"posts":       posts,
+		"collections": collections,
 	}
+	if site.PageTree != nil {
+		context["pages"] = site.PageTree.makeContext()
+	}
+
+	return context
 }
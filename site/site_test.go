@@ -6,6 +6,8 @@ import (
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/michaelenger/brage/files"
 )
 
 var exampleConfig = `
@@ -24,6 +26,34 @@ data:
     - "[Dislocation] is super fun!"
 `
 
+// Make a markdown renderer for a test, failing if the config is invalid.
+func newTestMarkdown(t *testing.T, config files.MarkdownConfig) *files.Markdown {
+	markdown, err := files.NewMarkdown(config)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	return markdown
+}
+
+// Write the given files to a temporary site directory, which is removed when
+// the test finishes.
+func writeTestSite(t *testing.T, files map[string]string) string {
+	directory := t.TempDir()
+
+	for filename, contents := range files {
+		filePath := path.Join(directory, filename)
+		if err := os.MkdirAll(path.Dir(filePath), 0755); err != nil {
+			t.Fatalf("%v", err)
+		}
+		if err := os.WriteFile(filePath, []byte(contents), 0644); err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+
+	return directory
+}
+
 func createExampleSite(t *testing.T) string {
 	// Site directory
 	temporaryDirectory, err := os.MkdirTemp("", "examplesite")
@@ -158,7 +188,7 @@ func TestLoad(t *testing.T) {
 	dirPath := createExampleSite(t)
 	defer os.RemoveAll(dirPath)
 
-	site, err := Load(dirPath)
+	site, err := Load(dirPath, Options{})
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
@@ -187,14 +217,14 @@ func TestLoad(t *testing.T) {
 	if site.SourceDirectory != dirPath {
 		t.Fatalf("Incorrect site.SourceDirectory: %v", site.SourceDirectory)
 	}
-	if site.Layouts[DefaultLayout] != "This is a layout" {
-		t.Fatalf("Incorrect site.Layouts[DefaultLayout]: %v", site.Layouts[DefaultLayout])
+	if site.Layouts[DefaultLayout].Template != "This is a layout" {
+		t.Fatalf("Incorrect site.Layouts[DefaultLayout]: %v", site.Layouts[DefaultLayout].Template)
 	}
-	if site.Layouts[PageLayout] != "This is the page layout" {
-		t.Fatalf("Incorrect site.Layouts[PageLayout]: %v", site.Layouts[PageLayout])
+	if site.Layouts[PageLayout].Template != "This is the page layout" {
+		t.Fatalf("Incorrect site.Layouts[PageLayout]: %v", site.Layouts[PageLayout].Template)
 	}
-	if site.Layouts[PostLayout] != "This is the post layout" {
-		t.Fatalf("Incorrect site.Layouts[PostLayout]: %v", site.Layouts[PostLayout])
+	if site.Layouts[PostLayout].Template != "This is the post layout" {
+		t.Fatalf("Incorrect site.Layouts[PostLayout]: %v", site.Layouts[PostLayout].Template)
 	}
 	if site.Config.Data["instagram"] != "https://www.instagram.com/youngfatigue/" {
 		t.Fatalf("Incorrect site.Config.Data[\"instagram\"]: %v", site.Config.Data["instagram"])
@@ -219,19 +249,19 @@ func TestLoadWithoutLayout(t *testing.T) {
 	os.Remove(path.Join(dirPath, "layout.html"))
 	os.Remove(path.Join(dirPath, "layout-post.html"))
 
-	site, err := Load(dirPath)
+	site, err := Load(dirPath, Options{})
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
 
-	if site.Layouts[DefaultLayout] != "{{{ content }}}" {
-		t.Fatalf("Incorrect site.Layouts[DefaultLayout]: %v", site.Layouts[DefaultLayout])
+	if site.Layouts[DefaultLayout].Template != "{{{ content }}}" {
+		t.Fatalf("Incorrect site.Layouts[DefaultLayout]: %v", site.Layouts[DefaultLayout].Template)
 	}
-	if site.Layouts[PageLayout] != "This is the page layout" {
-		t.Fatalf("Incorrect site.Layouts[PageLayout]: %v", site.Layouts[PageLayout])
+	if site.Layouts[PageLayout].Template != "This is the page layout" {
+		t.Fatalf("Incorrect site.Layouts[PageLayout]: %v", site.Layouts[PageLayout].Template)
 	}
-	if site.Layouts[PostLayout] != "{{{ content }}}" {
-		t.Fatalf("Incorrect site.Layouts[PostLayout]: %v", site.Layouts[PostLayout])
+	if site.Layouts[PostLayout].Template != "{{{ content }}}" {
+		t.Fatalf("Incorrect site.Layouts[PostLayout]: %v", site.Layouts[PostLayout].Template)
 	}
 }
 
@@ -240,7 +270,7 @@ func TestLoadWithoutPartials(t *testing.T) {
 	defer os.RemoveAll(dirPath)
 	os.RemoveAll(path.Join(dirPath, "partials"))
 
-	_, err := Load(dirPath)
+	_, err := Load(dirPath, Options{})
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
@@ -250,7 +280,7 @@ func TestLoadMissingSite(t *testing.T) {
 	dirPath := createExampleSite(t)
 	os.RemoveAll(dirPath)
 
-	_, err := Load(dirPath)
+	_, err := Load(dirPath, Options{})
 	if err == nil {
 		t.Fatalf("Expected error but got nil")
 	}
@@ -261,7 +291,7 @@ func TestLoadMissingConfig(t *testing.T) {
 	defer os.RemoveAll(dirPath)
 	os.Remove(path.Join(dirPath, "config.yaml"))
 
-	_, err := Load(dirPath)
+	_, err := Load(dirPath, Options{})
 	if err == nil {
 		t.Fatalf("Expected error but got nil")
 	}
@@ -272,7 +302,7 @@ func TestLoadMissingPages(t *testing.T) {
 	defer os.RemoveAll(dirPath)
 	os.RemoveAll(path.Join(dirPath, "pages"))
 
-	_, err := Load(dirPath)
+	_, err := Load(dirPath, Options{})
 	if err == nil {
 		t.Fatalf("Expected error but got nil")
 	}
@@ -281,36 +311,47 @@ func TestLoadMissingPages(t *testing.T) {
 func TestMakeContext(t *testing.T) {
 	date, _ := time.Parse("2006-01-02", "2010-09-08")
 	site := Site{
-		SiteConfig{
-			"Title",
-			"Description",
-			"image.png",
-			"Person McPersonface",
-			"https://example.org",
-			map[string]string{
+		Config: SiteConfig{
+			Title:       "Title",
+			Description: "Description",
+			Image:       "image.png",
+			Author:      "Person McPersonface",
+			RootUrl:     "https://example.org",
+			Redirects: map[string]string{
 				"redirect": "https://google.com",
 			},
-			DataMap{
+			Data: DataMap{
 				"one": 1,
 				"two": "two",
 			},
 		},
-		"/tmp",
-		map[LayoutType]string{
-			DefaultLayout: "",
-			PageLayout:    "",
-			PostLayout:    "",
+		SourceDirectory: "/tmp",
+		Layouts: map[string]Layout{
+			DefaultLayout: {},
+			PageLayout:    {},
+			PostLayout:    {},
 		},
-		[]Page{},
-		map[string]string{},
-		[]Post{
+		Pages:    []Page{},
+		Partials: map[string]string{},
+		Posts: []Post{
 			{
-				"/blog/first-post",
-				"First Post",
-				"",
-				"",
-				date,
-				"This is a test",
+				Path:     "/blog/first-post",
+				Title:    "First Post",
+				Date:     date,
+				Template: "This is a test",
+			},
+		},
+		Collections: map[string]Collection{
+			"projects": {
+				Name: "projects",
+				Entries: []Post{
+					{
+						Path:     "/projects/brage",
+						Title:    "Brage",
+						Date:     date,
+						Template: "This is a project",
+					},
+				},
 			},
 		},
 	}
@@ -330,6 +371,15 @@ func TestMakeContext(t *testing.T) {
 				"date":  "2010-09-08",
 			},
 		},
+		"collections": map[string]interface{}{
+			"projects": []map[string]string{
+				{
+					"path":  "/projects/brage",
+					"title": "Brage",
+					"date":  "2010-09-08",
+				},
+			},
+		},
 	}
 
 	result := site.MakeContext()
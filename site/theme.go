@@ -0,0 +1,48 @@
+package site
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Get the path to the directory of a theme. The theme is either a path
+// (relative to the site directory) or the name of a theme in the "themes"
+// directory.
+func themeDirectory(siteDirectory string, theme string) string {
+	if theme == "" {
+		return ""
+	}
+	if filepath.IsAbs(theme) {
+		return theme
+	}
+	if strings.HasPrefix(theme, ".") || strings.Contains(theme, "/") {
+		return path.Join(siteDirectory, theme)
+	}
+
+	return path.Join(siteDirectory, "themes", theme)
+}
+
+// Get the directories which files are loaded from, with the ones taking
+// precedence last.
+func (site Site) Directories() []string {
+	if site.ThemeDirectory == "" {
+		return []string{site.SourceDirectory}
+	}
+
+	return []string{site.ThemeDirectory, site.SourceDirectory}
+}
+
+// Find a file relative to the site directory, falling back to the theme.
+func (site Site) ResolvePath(relativePath string) (string, bool) {
+	directories := site.Directories()
+	for i := len(directories) - 1; i >= 0; i-- {
+		fullPath := path.Join(directories[i], relativePath)
+		if _, err := os.Stat(fullPath); err == nil {
+			return fullPath, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,118 @@
+package site
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func createExampleTheme(t *testing.T, dirPath string) string {
+	themePath := path.Join(dirPath, "themes", "simple")
+
+	themeFiles := map[string]string{
+		"config.yaml":         "title: Theme Title\nroot_url: https://theme.example.org/\ndata:\n  theme_color: blue\n",
+		"layout.html":         "This is the theme layout",
+		"layouts/wide.html":   "This is the wide theme layout",
+		"partials/one.html":   "Theme partial one",
+		"partials/theme.html": "Theme partial",
+		"assets/theme.css":    "body { color: blue; }",
+	}
+	for filename, contents := range themeFiles {
+		filePath := path.Join(themePath, filename)
+		if err := os.MkdirAll(path.Dir(filePath), 0755); err != nil {
+			t.Fatalf("Unable to create example theme: %v", err)
+		}
+		if err := os.WriteFile(filePath, []byte(contents), 0644); err != nil {
+			t.Fatalf("Unable to create example theme: %v", err)
+		}
+	}
+
+	configFile, err := os.OpenFile(path.Join(dirPath, "config.yaml"), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("Unable to create example theme: %v", err)
+	}
+	defer configFile.Close()
+	if _, err = configFile.WriteString("theme: simple\n"); err != nil {
+		t.Fatalf("Unable to create example theme: %v", err)
+	}
+
+	return themePath
+}
+
+func TestThemeDirectory(t *testing.T) {
+	var tests map[string]string = map[string]string{
+		"":                "",
+		"simple":          "/site/themes/simple",
+		"./shared/simple": "/site/shared/simple",
+		"../shared":       "/shared",
+		"vendor/theme":    "/site/vendor/theme",
+		"/opt/theme":      "/opt/theme",
+	}
+
+	for theme, expected := range tests {
+		result := themeDirectory("/site", theme)
+		if result != expected {
+			t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+		}
+	}
+}
+
+func TestLoadWithTheme(t *testing.T) {
+	dirPath := createExampleSite(t)
+	defer os.RemoveAll(dirPath)
+	themePath := createExampleTheme(t, dirPath)
+
+	site, err := Load(dirPath, Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if site.ThemeDirectory != themePath {
+		t.Fatalf("Incorrect site.ThemeDirectory: %v", site.ThemeDirectory)
+	}
+	if site.Config.Title != "Young Fatigue" {
+		t.Fatalf("Incorrect site.Config.Title: %v", site.Config.Title)
+	}
+	if site.Config.Data["theme_color"] != "blue" {
+		t.Fatalf("Incorrect site.Config.Data[\"theme_color\"]: %v", site.Config.Data["theme_color"])
+	}
+	if site.Config.Data["instagram"] != "https://www.instagram.com/youngfatigue/" {
+		t.Fatalf("Incorrect site.Config.Data[\"instagram\"]: %v", site.Config.Data["instagram"])
+	}
+	if site.Layouts[DefaultLayout].Template != "This is a layout" {
+		t.Fatalf("Incorrect site.Layouts[DefaultLayout]: %v", site.Layouts[DefaultLayout].Template)
+	}
+	if site.Layouts["wide"].Template != "This is the wide theme layout" {
+		t.Fatalf("Incorrect site.Layouts[\"wide\"]: %v", site.Layouts["wide"].Template)
+	}
+	if site.Partials["one"] != "" {
+		t.Fatalf("Incorrect site.Partials[\"one\"]: %v", site.Partials["one"])
+	}
+	if site.Partials["theme"] != "Theme partial" {
+		t.Fatalf("Incorrect site.Partials[\"theme\"]: %v", site.Partials["theme"])
+	}
+
+	assetPath, ok := site.ResolvePath("assets/theme.css")
+	if !ok || assetPath != path.Join(themePath, "assets", "theme.css") {
+		t.Fatalf("Incorrect asset path: %v", assetPath)
+	}
+	configPath, ok := site.ResolvePath("config.yaml")
+	if !ok || configPath != path.Join(dirPath, "config.yaml") {
+		t.Fatalf("Incorrect config path: %v", configPath)
+	}
+	if _, ok := site.ResolvePath("nope.txt"); ok {
+		t.Fatalf("Expected no path for a missing file")
+	}
+}
+
+func TestLoadWithMissingTheme(t *testing.T) {
+	dirPath := createExampleSite(t)
+	defer os.RemoveAll(dirPath)
+	themePath := createExampleTheme(t, dirPath)
+	os.RemoveAll(themePath)
+
+	_, err := Load(dirPath, Options{})
+	if err == nil {
+		t.Fatalf("Expected error but got nil")
+	}
+}
@@ -0,0 +1,117 @@
+package site
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/michaelenger/brage/files"
+)
+
+// Config for the table of contents of pages and posts, limiting which
+// heading levels are included.
+type TocConfig struct {
+	Enabled    bool
+	StartLevel int `yaml:"start_level"`
+	EndLevel   int `yaml:"end_level"`
+}
+
+// Check whether the table of contents has been configured, which is needed
+// for the headings to get an ID to link to.
+func (config TocConfig) configured() bool {
+	return config.Enabled || config.StartLevel != 0 || config.EndLevel != 0
+}
+
+// Get the first and last heading levels included in the table of contents,
+// defaulting to the second and third levels.
+func (config TocConfig) levels() (int, int) {
+	start, end := config.StartLevel, config.EndLevel
+	if start == 0 {
+		start = 2
+	}
+	if end == 0 {
+		end = 3
+	}
+
+	return start, end
+}
+
+// An entry in the table of contents, with the headings nested under it.
+type tocEntry struct {
+	heading  files.Heading
+	children []*tocEntry
+}
+
+// Nest the headings within the given levels based on their level, skipping
+// any without an ID.
+func nestHeadings(headings []files.Heading, start int, end int) []*tocEntry {
+	root := &tocEntry{}
+	stack := []*tocEntry{root}
+
+	for _, heading := range headings {
+		if heading.Id == "" || heading.Level < start || heading.Level > end {
+			continue
+		}
+
+		for len(stack) > 1 && stack[len(stack)-1].heading.Level >= heading.Level {
+			stack = stack[:len(stack)-1]
+		}
+
+		entry := &tocEntry{heading: heading}
+		parent := stack[len(stack)-1]
+		parent.children = append(parent.children, entry)
+		stack = append(stack, entry)
+	}
+
+	return root.children
+}
+
+// Write the entries as a nested list.
+func writeTocHtml(builder *strings.Builder, entries []*tocEntry) {
+	builder.WriteString("<ul>")
+	for _, entry := range entries {
+		fmt.Fprintf(builder, `<li><a href="#%v">%v</a>`, html.EscapeString(entry.heading.Id), html.EscapeString(entry.heading.Title))
+		if len(entry.children) > 0 {
+			writeTocHtml(builder, entry.children)
+		}
+		builder.WriteString("</li>")
+	}
+	builder.WriteString("</ul>")
+}
+
+// Make the list of entries used in the context.
+func makeTocItems(entries []*tocEntry) []map[string]interface{} {
+	items := make([]map[string]interface{}, len(entries))
+	for i, entry := range entries {
+		items[i] = map[string]interface{}{
+			"id":           entry.heading.Id,
+			"title":        entry.heading.Title,
+			"level":        entry.heading.Level,
+			"url":          "#" + entry.heading.Id,
+			"children":     makeTocItems(entry.children),
+			"has_children": len(entry.children) > 0,
+		}
+	}
+
+	return items
+}
+
+// Make the table of contents for the headings of a page or post, both as an
+// HTML list and as a nested list of items.
+func makeTocContext(headings []files.Heading, config TocConfig) map[string]interface{} {
+	start, end := config.levels()
+	entries := nestHeadings(headings, start, end)
+
+	tocHtml := ""
+	if len(entries) > 0 {
+		var builder strings.Builder
+		writeTocHtml(&builder, entries)
+		tocHtml = builder.String()
+	}
+
+	return map[string]interface{}{
+		"html":      tocHtml,
+		"items":     makeTocItems(entries),
+		"has_items": len(entries) > 0,
+	}
+}
@@ -0,0 +1,125 @@
+package site
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/michaelenger/brage/files"
+)
+
+var testHeadings = []files.Heading{
+	{Level: 1, Id: "title", Title: "Title"},
+	{Level: 2, Id: "intro", Title: "Intro"},
+	{Level: 3, Id: "background", Title: "Background"},
+	{Level: 4, Id: "details", Title: "Details"},
+	{Level: 2, Id: "usage", Title: "Usage & More"},
+}
+
+func TestMakeTocContext(t *testing.T) {
+	result := makeTocContext(testHeadings, TocConfig{})
+
+	expectedHtml := `<ul><li><a href="#intro">Intro</a><ul><li><a href="#background">Background</a></li></ul></li><li><a href="#usage">Usage &amp; More</a></li></ul>`
+	if result["html"] != expectedHtml {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result["html"], expectedHtml)
+	}
+
+	expectedItems := []map[string]interface{}{
+		{
+			"id":    "intro",
+			"title": "Intro",
+			"level": 2,
+			"url":   "#intro",
+			"children": []map[string]interface{}{
+				{
+					"id":           "background",
+					"title":        "Background",
+					"level":        3,
+					"url":          "#background",
+					"children":     []map[string]interface{}{},
+					"has_children": false,
+				},
+			},
+			"has_children": true,
+		},
+		{
+			"id":           "usage",
+			"title":        "Usage & More",
+			"level":        2,
+			"url":          "#usage",
+			"children":     []map[string]interface{}{},
+			"has_children": false,
+		},
+	}
+	if !reflect.DeepEqual(result["items"], expectedItems) {
+		t.Fatalf("Received:\n%+v\nExpected:\n%+v", result["items"], expectedItems)
+	}
+	if result["has_items"] != true {
+		t.Fatalf("Incorrect has_items: %v", result["has_items"])
+	}
+}
+
+func TestMakeTocContextLevels(t *testing.T) {
+	result := makeTocContext(testHeadings, TocConfig{StartLevel: 3, EndLevel: 4})
+
+	expectedHtml := `<ul><li><a href="#background">Background</a><ul><li><a href="#details">Details</a></li></ul></li></ul>`
+	if result["html"] != expectedHtml {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result["html"], expectedHtml)
+	}
+
+	result = makeTocContext(nil, TocConfig{})
+	if result["html"] != "" || result["has_items"] != false {
+		t.Fatalf("Incorrect empty toc: %+v", result)
+	}
+}
+
+func TestPostToc(t *testing.T) {
+	markdown := newTestMarkdown(t, files.MarkdownConfig{Toc: true})
+
+	file := files.File{
+		Type:    files.MarkdownFile,
+		Path:    "/tmp/toc.md",
+		Content: []byte("## Setup\n\nText\n\n## Setup\n\n### The `config` file\n"),
+	}
+
+	post := MakePost(file, "/toc", markdown)
+	expected := []files.Heading{
+		{Level: 2, Id: "setup", Title: "Setup"},
+		{Level: 2, Id: "setup-1", Title: "Setup"},
+		{Level: 3, Id: "the-config-file", Title: "The config file"},
+	}
+	if !reflect.DeepEqual(post.Headings, expected) {
+		t.Fatalf("Received:\n%+v\nExpected:\n%+v", post.Headings, expected)
+	}
+
+	site := Site{
+		Layouts: map[string]Layout{PostLayout: {Template: "{{{ post.toc.html }}}"}},
+	}
+	result, err := post.Render(site)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expectedHtml := `<ul><li><a href="#setup">Setup</a></li><li><a href="#setup-1">Setup</a><ul><li><a href="#the-config-file">The config file</a></li></ul></li></ul>`
+	if result != expectedHtml {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expectedHtml)
+	}
+}
+
+func TestPostTocWithoutHeadingIds(t *testing.T) {
+	file := files.File{
+		Type:    files.MarkdownFile,
+		Path:    "/tmp/toc.md",
+		Content: []byte("## Setup\n\nText\n"),
+	}
+
+	// Headings without an ID can't be linked to
+	post := MakePost(file, "/toc", newTestMarkdown(t, files.MarkdownConfig{}))
+	result := makeTocContext(post.Headings, TocConfig{})
+	if result["html"] != "" || result["has_items"] != false {
+		t.Fatalf("Incorrect empty toc: %+v", result)
+	}
+
+	if (TocConfig{}).configured() || !(TocConfig{Enabled: true}).configured() || !(TocConfig{EndLevel: 4}).configured() {
+		t.Fatalf("Incorrect configured toc")
+	}
+}
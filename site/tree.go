@@ -0,0 +1,177 @@
+package site
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/michaelenger/brage/files"
+)
+
+// A node in the hierarchy of pages, representing either a page or a
+// directory without an index page.
+type PageNode struct {
+	Name     string
+	Path     string
+	Page     *Page
+	Children []*PageNode
+}
+
+// Get the title of the node.
+func (node *PageNode) Title() string {
+	if node.Page != nil {
+		return node.Page.Title()
+	}
+
+	return files.PathToTitle(node.Path)
+}
+
+// Get the weight of the node, used when sorting.
+func (node *PageNode) weight() int {
+	if node.Page != nil {
+		if val, ok := node.Page.Metadata["weight"].(int); ok {
+			return val
+		}
+	}
+
+	return 0
+}
+
+// Get the child node with the given name, creating it if needed.
+func (node *PageNode) child(name string) *PageNode {
+	for _, child := range node.Children {
+		if child.Name == name {
+			return child
+		}
+	}
+
+	child := &PageNode{
+		Name: name,
+		Path: path.Join(node.Path, name),
+	}
+	node.Children = append(node.Children, child)
+
+	return child
+}
+
+// Sort the children of the node (and their children) by weight and title.
+func (node *PageNode) sort() {
+	sort.SliceStable(node.Children, func(i, j int) bool {
+		a, b := node.Children[i], node.Children[j]
+		if a.weight() != b.weight() {
+			return a.weight() < b.weight()
+		}
+		return a.Title() < b.Title()
+	})
+
+	for _, child := range node.Children {
+		child.sort()
+	}
+}
+
+// Find the trail of nodes leading to the given path, starting with the root.
+func (node *PageNode) trail(pagePath string) []*PageNode {
+	trail := []*PageNode{node}
+	if pagePath == "/" {
+		return trail
+	}
+
+	current := node
+	for _, name := range strings.Split(strings.Trim(pagePath, "/"), "/") {
+		var next *PageNode
+		for _, child := range current.Children {
+			if child.Name == name {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+
+		trail = append(trail, next)
+		current = next
+	}
+
+	return trail
+}
+
+// Make a summary of the node, without its children.
+func (node *PageNode) makeSummary() map[string]interface{} {
+	return map[string]interface{}{
+		"name":         node.Name,
+		"path":         node.Path,
+		"title":        node.Title(),
+		"identifier":   files.PathToIdentifier(node.Path),
+		"has_page":     node.Page != nil,
+		"has_children": len(node.Children) > 0,
+	}
+}
+
+// Make the context for the node and all its descendants. The children are
+// available both as an ordered list and keyed by their name in "sections".
+func (node *PageNode) makeContext() map[string]interface{} {
+	context := node.makeSummary()
+
+	children := make([]map[string]interface{}, len(node.Children))
+	sections := map[string]interface{}{}
+	for i, child := range node.Children {
+		children[i] = child.makeContext()
+		sections[child.Name] = children[i]
+	}
+	context["children"] = children
+	context["sections"] = sections
+
+	return context
+}
+
+// Build the hierarchy of pages based on their paths.
+func buildPageTree(pages []Page) *PageNode {
+	root := &PageNode{Path: "/"}
+
+	for i := range pages {
+		node := root
+		if pages[i].Path != "/" {
+			for _, name := range strings.Split(strings.Trim(pages[i].Path, "/"), "/") {
+				node = node.child(name)
+			}
+		}
+		node.Page = &pages[i]
+	}
+
+	root.sort()
+
+	return root
+}
+
+// Add the parent, children and breadcrumbs of the page at the given path to
+// the page context.
+func (node *PageNode) addPageContext(pagePath string, pageContext map[string]interface{}) {
+	trail := node.trail(pagePath)
+	if trail == nil {
+		return
+	}
+
+	breadcrumbs := []map[string]interface{}{}
+	for i, ancestor := range trail {
+		if ancestor.Page == nil {
+			continue
+		}
+
+		crumb := ancestor.makeSummary()
+		crumb["current"] = i == len(trail)-1
+		breadcrumbs = append(breadcrumbs, crumb)
+	}
+	pageContext["breadcrumbs"] = breadcrumbs
+
+	if len(trail) > 1 {
+		pageContext["parent"] = trail[len(trail)-2].makeSummary()
+	}
+
+	current := trail[len(trail)-1]
+	children := make([]map[string]interface{}, len(current.Children))
+	for i, child := range current.Children {
+		children[i] = child.makeSummary()
+	}
+	pageContext["children"] = children
+}
@@ -0,0 +1,94 @@
+package site
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testTreePages = []Page{
+	{Path: "/"},
+	{Path: "/docs"},
+	{Path: "/docs/usage", Metadata: map[string]interface{}{"weight": 1}},
+	{Path: "/docs/install", Metadata: map[string]interface{}{"weight": 2}},
+	{Path: "/docs/advanced/config"},
+	{Path: "/about", Metadata: map[string]interface{}{"title": "About Us"}},
+}
+
+func TestBuildPageTree(t *testing.T) {
+	tree := buildPageTree(testTreePages)
+
+	var names func(node *PageNode) []string
+	names = func(node *PageNode) []string {
+		result := []string{node.Path}
+		for _, child := range node.Children {
+			result = append(result, names(child)...)
+		}
+		return result
+	}
+
+	expected := []string{
+		"/",
+		"/about",
+		"/docs",
+		"/docs/advanced",
+		"/docs/advanced/config",
+		"/docs/usage",
+		"/docs/install",
+	}
+	result := names(tree)
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("Received:\n%+v\nExpected:\n%+v", result, expected)
+	}
+
+	advanced := tree.trail("/docs/advanced")
+	if advanced == nil || advanced[2].Page != nil {
+		t.Fatalf("Expected /docs/advanced to be a directory without a page")
+	}
+	if tree.trail("/nope") != nil {
+		t.Fatalf("Expected no trail for a missing page")
+	}
+}
+
+func TestPageTreeContext(t *testing.T) {
+	tree := buildPageTree(testTreePages)
+	context := tree.makeContext()
+
+	docs := context["sections"].(map[string]interface{})["docs"].(map[string]interface{})
+	if docs["title"] != "Docs" {
+		t.Fatalf("Incorrect title: %v", docs["title"])
+	}
+
+	children := docs["children"].([]map[string]interface{})
+	if len(children) != 3 || children[0]["path"] != "/docs/advanced" || children[0]["has_page"] != false {
+		t.Fatalf("Incorrect children: %+v", children)
+	}
+}
+
+func TestPageRenderWithTree(t *testing.T) {
+	site := Site{
+		Layouts:  map[string]Layout{PageLayout: {Template: "{{{ content }}}"}},
+		Pages:    testTreePages,
+		PageTree: buildPageTree(testTreePages),
+	}
+	page := Page{
+		Path: "/docs",
+		Template: `{{ #page.breadcrumbs }}[{{ title }}{{ #current }}*{{ /current }}]{{ /page.breadcrumbs }}
+{{ page.parent.path }}
+{{ #page.children }}{{ path }} {{ /page.children }}
+{{ #site.pages.sections.docs.children }}{{ title }} {{ /site.pages.sections.docs.children }}`,
+	}
+
+	result, err := page.Render(site)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := `[Home][Docs*]
+/
+/docs/advanced /docs/usage /docs/install 
+Advanced Usage Install `
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+}
@@ -0,0 +1,245 @@
+package site
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/michaelenger/brage/files"
+)
+
+// A link to a page or post.
+type Link struct {
+	Title string
+	Path  string
+}
+
+// Pattern matching a wiki link which has been rendered from markdown.
+var wikiLinkPattern = regexp.MustCompile(`<a class="wikilink" href="` + files.WikiLinkScheme + `([^"]*)">(.*?)</a>`)
+
+// Normalise the name of a page or post used as a wiki link target.
+func normaliseLinkName(name string) string {
+	name = strings.ToLower(strings.Trim(strings.TrimSpace(name), "/"))
+	for _, extension := range []string{".md", ".markdown", ".html"} {
+		name = strings.TrimSuffix(name, extension)
+	}
+
+	return name
+}
+
+// Make the names which a page or post can be linked to with, in order of
+// preference: the file it was loaded from, its path, its title and its file
+// name.
+func linkNames(site Site, source string, pagePath string, title string) []string {
+	names := []string{}
+	if source != "" {
+		if relative, err := filepath.Rel(site.SourceDirectory, source); err == nil && !strings.HasPrefix(relative, "..") {
+			names = append(names, relative, strings.TrimPrefix(relative, "pages/"))
+		}
+	}
+	names = append(names, pagePath, title)
+	if source != "" {
+		names = append(names, path.Base(source))
+	}
+
+	return names
+}
+
+// Make the lookup of the pages and posts which wiki links can point to.
+func makeLinkTargets(site Site) map[string]Link {
+	type candidate struct {
+		names []string
+		link  Link
+	}
+
+	candidates := []candidate{}
+	for _, page := range site.Pages {
+		candidates = append(candidates, candidate{
+			linkNames(site, page.Source, page.Path, page.Title()),
+			Link{Title: page.Title(), Path: page.Path},
+		})
+	}
+	for _, name := range sortedCollectionNames(site) {
+		for _, post := range site.Collections[name].Entries {
+			candidates = append(candidates, candidate{
+				linkNames(site, post.Source, post.Path, post.Title),
+				Link{Title: post.Title, Path: post.Path},
+			})
+		}
+	}
+
+	// Names which are more specific take precedence over the less specific
+	// names of other pages and posts, otherwise the first path wins
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].link.Path < candidates[j].link.Path
+	})
+
+	targets := map[string]Link{}
+	for i := 0; ; i++ {
+		added := false
+		for _, candidate := range candidates {
+			if i >= len(candidate.names) {
+				continue
+			}
+			added = true
+			name := normaliseLinkName(candidate.names[i])
+			if _, ok := targets[name]; !ok {
+				targets[name] = candidate.link
+			}
+		}
+		if !added {
+			break
+		}
+	}
+
+	return targets
+}
+
+// Get the names of the collections in a stable order.
+func sortedCollectionNames(site Site) []string {
+	names := make([]string, 0, len(site.Collections))
+	for name := range site.Collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Find the page or post a wiki link points to, trying the name as written
+// and then as it would be if it was a slug.
+func findLinkTarget(targets map[string]Link, target string) (Link, bool) {
+	name := normaliseLinkName(target)
+	if link, ok := targets[name]; ok {
+		return link, true
+	}
+
+	segments := strings.Split(name, "/")
+	for i := range segments {
+		segments[i] = slugify(segments[i])
+	}
+	link, ok := targets[strings.Join(segments, "/")]
+
+	return link, ok
+}
+
+// Find the ID of the heading a wiki link fragment points to, which is either
+// its ID or its title.
+func findHeadingId(headings []files.Heading, fragment string) (string, bool) {
+	slug := slugify(fragment)
+	for _, heading := range headings {
+		if heading.Id != "" && (heading.Id == fragment || heading.Id == slug || strings.EqualFold(heading.Title, fragment)) {
+			return heading.Id, true
+		}
+	}
+
+	return "", false
+}
+
+// Replace the wiki links in some HTML with links to the pages and posts they
+// point to (and the headings in them, keyed by path), calling found with
+// each of them. Links to anything which doesn't exist are replaced with their
+// label and a warning is logged.
+func replaceWikiLinks(content string, source string, targets map[string]Link, headings map[string][]files.Heading, found func(Link)) string {
+	return wikiLinkPattern.ReplaceAllStringFunc(content, func(match string) string {
+		submatches := wikiLinkPattern.FindStringSubmatch(match)
+		target, label := html.UnescapeString(submatches[1]), submatches[2]
+
+		name, fragment, _ := strings.Cut(target, "#")
+		link, ok := findLinkTarget(targets, name)
+		if !ok {
+			logger := log.Default()
+			logger.Printf("Unknown link target in %v: %v", source, target)
+			return fmt.Sprintf(`<span class="wikilink wikilink-missing">%v</span>`, label)
+		}
+
+		if found != nil {
+			found(link)
+		}
+
+		href := link.Path
+		if fragment != "" {
+			if id, ok := findHeadingId(headings[link.Path], fragment); ok {
+				href += "#" + id
+			} else {
+				logger := log.Default()
+				logger.Printf("Unknown heading in link in %v: %v", source, target)
+			}
+		}
+
+		return fmt.Sprintf(`<a class="wikilink"%v>%v</a>`, htmlAttribute("href", href), label)
+	})
+}
+
+// Resolve the wiki links in the pages and posts of the site, filling in the
+// backlinks of the pages and posts they point to.
+func (site Site) resolveWikiLinks() {
+	backlinks := map[string][]Link{}
+	addBacklink := func(from Link) func(Link) {
+		return func(to Link) {
+			for _, existing := range backlinks[to.Path] {
+				if existing == from {
+					return
+				}
+			}
+			backlinks[to.Path] = append(backlinks[to.Path], from)
+		}
+	}
+
+	headings := map[string][]files.Heading{}
+	for _, page := range site.Pages {
+		headings[page.Path] = page.Headings
+	}
+	for _, collection := range site.Collections {
+		for _, post := range collection.Entries {
+			headings[post.Path] = post.Headings
+		}
+	}
+
+	// The pages and posts are updated in place
+	for i := range site.Pages {
+		page := &site.Pages[i]
+		from := Link{Title: page.Title(), Path: page.Path}
+		page.Template = replaceWikiLinks(page.Template, page.Source, site.LinkTargets, headings, addBacklink(from))
+	}
+	for _, name := range sortedCollectionNames(site) {
+		entries := site.Collections[name].Entries
+		for i := range entries {
+			from := Link{Title: entries[i].Title, Path: entries[i].Path}
+			entries[i].Template = replaceWikiLinks(entries[i].Template, entries[i].Source, site.LinkTargets, headings, addBacklink(from))
+		}
+	}
+
+	for _, links := range backlinks {
+		sort.Slice(links, func(i, j int) bool {
+			return links[i].Path < links[j].Path
+		})
+	}
+
+	for i := range site.Pages {
+		site.Pages[i].Backlinks = backlinks[site.Pages[i].Path]
+	}
+	for _, collection := range site.Collections {
+		for i := range collection.Entries {
+			collection.Entries[i].Backlinks = backlinks[collection.Entries[i].Path]
+		}
+	}
+}
+
+// Make the list of backlinks used in the context.
+func makeBacklinksContext(backlinks []Link) []map[string]string {
+	items := make([]map[string]string, len(backlinks))
+	for i, link := range backlinks {
+		items[i] = map[string]string{
+			"title": link.Title,
+			"path":  link.Path,
+		}
+	}
+
+	return items
+}
@@ -0,0 +1,91 @@
+package site
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/michaelenger/brage/files"
+)
+
+func createWikiLinkSite(t *testing.T) string {
+	return writeTestSite(t, map[string]string{
+		"pages/index.md":       "Read [[Some Page]] and [[posts/first-post|the first post]].",
+		"pages/some-page.md":   "---\ntitle: Some Page\n---\nBack to [[index|home]], see [[First Post#Part Two]] or [[Missing]].",
+		"posts/first-post.md":  "---\ntitle: First Post\ndate: 2024-01-02\n---\nSee [[about/team]].\n\n## Part Two\n",
+		"pages/about/team.md":  "# Team",
+		"layouts/default.html": "{{{ page.template }}}",
+		"config.yaml":          "title: Wiki\nmarkdown:\n  wiki_links: true\ncollections:\n  posts:\n    permalink: /blog/:year/:slug\n",
+	})
+}
+
+func TestReplaceWikiLinks(t *testing.T) {
+	targets := map[string]Link{
+		"posts/first-post": {Title: "First Post", Path: "/blog/first-post"},
+		"some page":        {Title: "Some Page", Path: "/some-page"},
+		"team":             {Title: "Team", Path: "/about/team"},
+	}
+
+	found := []Link{}
+	result := replaceWikiLinks(
+		`<a class="wikilink" href="wikilink:posts/first-post.md">First</a> <a class="wikilink" href="wikilink:Some Page#The End">Some</a> <a class="wikilink" href="wikilink:Team#Nope">Team</a> <a class="wikilink" href="wikilink:nope">Nope</a>`,
+		"test.md",
+		targets,
+		map[string][]files.Heading{"/some-page": {{Level: 2, Id: "the-end-1", Title: "The End"}}},
+		func(link Link) { found = append(found, link) },
+	)
+	expected := `<a class="wikilink" href="/blog/first-post">First</a> <a class="wikilink" href="/some-page#the-end-1">Some</a> <a class="wikilink" href="/about/team">Team</a> <span class="wikilink wikilink-missing">Nope</span>`
+	if result != expected {
+		t.Fatalf("Result:\n%v\nExpected:\n%v", result, expected)
+	}
+
+	expectedFound := []Link{targets["posts/first-post"], targets["some page"], targets["team"]}
+	if !reflect.DeepEqual(found, expectedFound) {
+		t.Fatalf("Received:\n%+v\nExpected:\n%+v", found, expectedFound)
+	}
+}
+
+func TestLoadWithWikiLinks(t *testing.T) {
+	dirPath := createWikiLinkSite(t)
+
+	site, err := Load(dirPath, Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	pages := map[string]Page{}
+	for _, page := range site.Pages {
+		pages[page.Path] = page
+	}
+
+	index := pages["/"].Template
+	if !strings.Contains(index, `<a class="wikilink" href="/some-page">Some Page</a>`) || !strings.Contains(index, `<a class="wikilink" href="/blog/2024/first-post">the first post</a>`) {
+		t.Fatalf("Incorrect links in index: %v", index)
+	}
+
+	somePage := pages["/some-page"].Template
+	if !strings.Contains(somePage, `<a class="wikilink" href="/blog/2024/first-post#part-two">First Post#Part Two</a>`) || !strings.Contains(somePage, `<span class="wikilink wikilink-missing">Missing</span>`) {
+		t.Fatalf("Incorrect links in some page: %v", somePage)
+	}
+
+	expected := []Link{{Title: "Home", Path: "/"}}
+	if !reflect.DeepEqual(pages["/some-page"].Backlinks, expected) {
+		t.Fatalf("Received:\n%+v\nExpected:\n%+v", pages["/some-page"].Backlinks, expected)
+	}
+
+	expected = []Link{{Title: "Home", Path: "/"}, {Title: "Some Page", Path: "/some-page"}}
+	if !reflect.DeepEqual(site.Posts[0].Backlinks, expected) {
+		t.Fatalf("Received:\n%+v\nExpected:\n%+v", site.Posts[0].Backlinks, expected)
+	}
+
+	expected = []Link{{Title: "First Post", Path: "/blog/2024/first-post"}}
+	if !reflect.DeepEqual(pages["/about/team"].Backlinks, expected) {
+		t.Fatalf("Received:\n%+v\nExpected:\n%+v", pages["/about/team"].Backlinks, expected)
+	}
+
+	context := site.Posts[0].makeContext(site)["post"].(map[string]interface{})
+	expectedContext := []map[string]string{{"title": "Home", "path": "/"}, {"title": "Some Page", "path": "/some-page"}}
+	if !reflect.DeepEqual(context["backlinks"], expectedContext) {
+		t.Fatalf("Received:\n%+v\nExpected:\n%+v", context["backlinks"], expectedContext)
+	}
+}